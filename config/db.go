@@ -1,8 +1,11 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"time"
+
+	"banking_system/logging"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -16,14 +19,44 @@ func InitDB() {
 
 	dsn := os.Getenv("DB_URL")
 	if dsn == "" {
-		log.Fatal("DB_URL not set in environment")
+		slog.Error("DB_URL not set in environment")
+		os.Exit(1)
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         logging.NewGormLogger(GetEnvDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond)),
+		TranslateError: true,
+	})
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		slog.Error("failed to get database handle", "error", err)
+		os.Exit(1)
+	}
+
+	// pool limits can be tuned per deployment, the defaults suit a single small instance
+	sqlDB.SetMaxOpenConns(GetEnvInt("DB_MAX_OPEN_CONNS", 25))
+	sqlDB.SetMaxIdleConns(GetEnvInt("DB_MAX_IDLE_CONNS", 10))
+	sqlDB.SetConnMaxLifetime(GetEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+	sqlDB.SetConnMaxIdleTime(GetEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute))
+
 	DB = db
 }
 
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		slog.Error("failed to get database handle", "error", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("failed to close database", "error", err)
+	}
+}
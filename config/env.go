@@ -0,0 +1,110 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+func GetEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("invalid number in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return f
+}
+
+// GetEnvList splits a comma separated variable, dropping empty entries.
+func GetEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// GetEnvRates reads a rate card written as comma separated months:rate pairs, e.g. "6:5.5,12:6.5".
+func GetEnvRates(key string, fallback map[int]float64) map[int]float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	rates := map[int]float64{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		months, rate, _ := strings.Cut(item, ":")
+		m, err := strconv.Atoi(strings.TrimSpace(months))
+		if err != nil {
+			slog.Warn("invalid rate card in environment, using default", "key", key, "value", value)
+			return fallback
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil {
+			slog.Warn("invalid rate card in environment, using default", "key", key, "value", value)
+			return fallback
+		}
+		rates[m] = r
+	}
+	return rates
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return b
+}
+
+func GetEnvString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+// SchemaVersion has to be bumped whenever the models below change, readiness checks compare against it
+const SchemaVersion = 22
+
+// tables are listed in dependency order, dropping happens in reverse
+func tables() []interface{} {
+	return []interface{}{
+		&models.SchemaMigration{},
+		&models.Bank{},
+		&models.Branch{},
+		&models.Customer{},
+		&models.CustomerAddress{},
+		&models.CustomerIdentity{},
+		&models.CustomerDocument{},
+		&models.CustomerMerge{},
+		&models.AccountSequence{},
+		&models.Account{},
+		&models.AccountCustomer{},
+		&models.AccountStatusChange{},
+		&models.TransactionLimit{},
+		&models.BenchmarkRate{},
+		&models.LoanProduct{},
+		&models.LoanApplication{},
+		&models.Loan{},
+		&models.LoanInstallment{},
+		&models.LoanTerms{},
+		&models.Collateral{},
+		&models.LoanGuarantor{},
+		&models.Repayment{},
+		&models.RepaymentAllocation{},
+		&models.Transaction{},
+		&models.FixedDeposit{},
+		&models.RecurringDeposit{},
+		&models.RecurringDepositInstallment{},
+		&models.StandingInstruction{},
+		&models.StandingInstructionExecution{},
+		&models.FraudAlert{},
+		&models.ApprovalRequest{},
+		&models.ApprovalEvent{},
+	}
+}
+
+func dropTables(db *gorm.DB) error {
+	all := tables()
+	reversed := make([]interface{}, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		reversed = append(reversed, all[i])
+	}
+	return db.Migrator().DropTable(reversed...)
+}
+
+// Migrate brings the schema up to SchemaVersion. Tables are only dropped when DB_RESET is set,
+// and the version is recorded once every table is in place, so readiness reflects what was applied.
+func Migrate(db *gorm.DB) error {
+	if GetEnvBool("DB_RESET", false) {
+		slog.Warn("DB_RESET is set, dropping all tables")
+		if err := dropTables(db); err != nil {
+			return fmt.Errorf("failed to drop tables: %w", err)
+		}
+	}
+
+	applied, err := AppliedVersion(db)
+	if err != nil {
+		return err
+	}
+	if applied >= SchemaVersion {
+		slog.Info("schema is up to date", "version", applied)
+		return nil
+	}
+
+	if err := backfillLoanProducts(db); err != nil {
+		return fmt.Errorf("failed to backfill loan products: %w", err)
+	}
+	if err := db.AutoMigrate(tables()...); err != nil {
+		return fmt.Errorf("failed to migrate schema from version %d to %d: %w", applied, SchemaVersion, err)
+	}
+	for _, table := range tables() {
+		if !db.Migrator().HasTable(table) {
+			return fmt.Errorf("table for %T is missing after migration", table)
+		}
+	}
+	if err := splitJointAccountType(db); err != nil {
+		return fmt.Errorf("failed to move joint accounts to the joint flag: %w", err)
+	}
+
+	if err := db.Create(&models.SchemaMigration{Version: SchemaVersion}).Error; err != nil {
+		return fmt.Errorf("failed to record schema version %d: %w", SchemaVersion, err)
+	}
+	slog.Info("schema migrated", "from", applied, "to", SchemaVersion)
+	return nil
+}
+
+// LegacyLoanProductCode is the product loans booked before the catalog existed are filed under.
+const LegacyLoanProductCode = "LEGACY"
+
+// backfillLoanProducts gives loans booked before products existed a product_id, so the NOT NULL
+// column can be added to a loans table that already has rows. They are filed under an inactive
+// legacy product that nothing new can be sold under.
+func backfillLoanProducts(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Loan{}) || migrator.HasColumn(&models.Loan{}, "ProductID") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.BenchmarkRate{}, &models.LoanProduct{}); err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE loans ADD COLUMN product_id bigint").Error; err != nil {
+			return err
+		}
+
+		var loans struct {
+			Count     int64
+			MaxAmount float64
+			MaxTerm   int
+			MaxRate   float64
+		}
+		if err := tx.Table("loans").
+			Select("COUNT(*) AS count, COALESCE(MAX(loan_amount), 0) AS max_amount, COALESCE(MAX(term_months), 0) AS max_term, COALESCE(MAX(loan_interest), 0) AS max_rate").
+			Scan(&loans).Error; err != nil {
+			return err
+		}
+		if loans.Count == 0 {
+			return nil
+		}
+
+		product := models.LoanProduct{
+			Code:          LegacyLoanProductCode,
+			Name:          "Loans booked before the product catalog",
+			MaxAmount:     loans.MaxAmount,
+			MinTermMonths: 1,
+			MaxTermMonths: max(loans.MaxTerm, 1),
+			RateType:      models.RateTypeFixed,
+			FixedRate:     loans.MaxRate,
+		}
+		if err := tx.Where("code = ?", product.Code).FirstOrCreate(&product).Error; err != nil {
+			return err
+		}
+		//Active has a database default, so it is switched off after the insert
+		if err := tx.Model(&product).Update("active", false).Error; err != nil {
+			return err
+		}
+		result := tx.Exec("UPDATE loans SET product_id = ? WHERE product_id IS NULL", product.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		slog.Info("loans filed under the legacy product", "product_id", product.ID, "loans", result.RowsAffected)
+		return nil
+	})
+}
+
+// splitJointAccountType moves accounts that were typed "joint" to the joint flag. Their product
+// wasn't kept, so they go back to savings, the type every account used to start as.
+func splitJointAccountType(db *gorm.DB) error {
+	result := db.Exec("UPDATE accounts SET joint = true, account_type = 'savings', version = version + 1 WHERE account_type = 'joint'")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("joint accounts moved to the joint flag", "accounts", result.RowsAffected)
+	}
+	return nil
+}
+
+// AppliedVersion is the highest schema version recorded as migrated, 0 before the first migration.
+func AppliedVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&models.SchemaMigration{}) {
+		return 0, nil
+	}
+	var version int
+	if err := db.Model(&models.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
@@ -18,41 +19,98 @@ func NewAccountController(service *services.AccountService) *AccountController {
 	return &AccountController{service: service}
 }
 
+// account_number is generated from the branch code, clients never choose it
+type CreateAccountRequest struct {
+	BranchID         uint    `json:"branch_id" binding:"required"`
+	AccountType      string  `json:"account_type" binding:"omitempty,oneof=savings current"`
+	Interest         float64 `json:"interest" binding:"gte=0,lte=100"`
+	OperatingMandate string  `json:"operating_mandate" binding:"omitempty,oneof=either_or_survivor former_or_survivor jointly"`
+}
+
+// balance, account_type, account_number, branch_id and created_at are server-owned: balance moves
+// only through deposits and withdrawals, joint follows the linked holders, and the account
+// number and IBAN are derived from the branch
+type UpdateAccountRequest struct {
+	Interest         *float64 `json:"interest" binding:"omitnil,gte=0,lte=100"`
+	OperatingMandate *string  `json:"operating_mandate" binding:"omitnil,oneof=either_or_survivor former_or_survivor jointly"`
+}
+
+func (r UpdateAccountRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Interest != nil {
+		changes["interest"] = *r.Interest
+	}
+	if r.OperatingMandate != nil {
+		changes["operating_mandate"] = *r.OperatingMandate
+	}
+	return changes
+}
+
 func (c *AccountController) CreateAccount(ctx *gin.Context) {
-	var account models.Account
-	if err := ctx.ShouldBindJSON(&account); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateAccountRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if err := c.service.Create(&account); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	account := models.Account{
+		BranchID:         req.BranchID,
+		AccountType:      req.AccountType,
+		Interest:         req.Interest,
+		OperatingMandate: req.OperatingMandate,
+	}
+
+	if err := c.service.Create(ctx.Request.Context(), &account); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, account.Version)
 	ctx.JSON(http.StatusCreated, account)
 }
 
 func (c *AccountController) GetAccountByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	accountDetail, err := c.service.GetAccountDetail(ctx.Request.Context(), uint(id), includeDeleted(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, accountDetail.Version)
+	ctx.JSON(http.StatusOK, accountDetail)
+}
+
+func (c *AccountController) GetAccountByNumber(ctx *gin.Context) {
+	accountDetail, err := c.service.GetByNumber(ctx.Request.Context(), ctx.Param("number"), includeDeleted(ctx))
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	accountDetail, err := c.service.GetAccountDetail(uint(id))
+	setETag(ctx, accountDetail.Version)
+	ctx.JSON(http.StatusOK, accountDetail)
+}
+
+func (c *AccountController) GetAccountByIBAN(ctx *gin.Context) {
+	accountDetail, err := c.service.GetByIBAN(ctx.Request.Context(), ctx.Param("iban"), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, accountDetail.Version)
 	ctx.JSON(http.StatusOK, accountDetail)
 }
 
 func (c *AccountController) GetAllAccounts(ctx *gin.Context) {
-	accounts, err := c.service.GetAll()
+	accounts, err := c.service.GetAll(ctx.Request.Context(), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, accounts)
@@ -61,81 +119,146 @@ func (c *AccountController) GetAllAccounts(ctx *gin.Context) {
 func (c *AccountController) UpdateAccount(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
-	account, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := ctx.ShouldBindJSON(account); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req UpdateAccountRequest
+	if !bindPatch(ctx, &req) {
 		return
 	}
 
-	if err := c.service.Update(account); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	account, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, account.Version)
 	ctx.JSON(http.StatusOK, account)
 }
 
 func (c *AccountController) DeleteAccount(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusNoContent)
 }
 
+func (c *AccountController) RestoreAccount(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	account, err := c.service.Restore(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, account.Version)
+	ctx.JSON(http.StatusOK, account)
+}
+
+type AccountHolderRequest struct {
+	Role string `json:"role" binding:"omitempty,oneof=primary_holder joint_holder nominee authorized_signatory guardian"`
+}
+
+type UpdateAccountHolderRequest struct {
+	Role string `json:"role" binding:"required,oneof=primary_holder joint_holder nominee authorized_signatory guardian"`
+}
+
 func (c *AccountController) AddCustomerToAccount(ctx *gin.Context) {
 	accountID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
 	customerID, err := strconv.Atoi(ctx.Param("customerId"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	//the body is optional, without a role the service picks primary or joint holder
+	var req AccountHolderRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
 		return
 	}
 
-	accountDetail, err := c.service.AddCustomer(uint(accountID), uint(customerID))
+	accountDetail, err := c.service.AddCustomer(ctx.Request.Context(), uint(accountID), uint(customerID), req.Role)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, accountDetail)
 }
 
+func (c *AccountController) UpdateAccountCustomerRole(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	customerID, err := strconv.Atoi(ctx.Param("customerId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req UpdateAccountHolderRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	accountDetail, err := c.service.UpdateCustomerRole(ctx.Request.Context(), uint(accountID), uint(customerID), req.Role)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, accountDetail.Version)
+	ctx.JSON(http.StatusOK, accountDetail)
+}
+
 func (c *AccountController) RemoveCustomerFromAccount(ctx *gin.Context) {
 	accountID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
 	customerID, err := strconv.Atoi(ctx.Param("customerId"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	if err := c.service.RemoveCustomer(uint(accountID), uint(customerID)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.RemoveCustomer(ctx.Request.Context(), uint(accountID), uint(customerID)); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -145,13 +268,13 @@ func (c *AccountController) RemoveCustomerFromAccount(ctx *gin.Context) {
 func (c *AccountController) GetAccountTransactions(ctx *gin.Context) {
 	accountID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
-	txs, err := c.service.GetTransactions(uint(accountID))
+	txs, err := c.service.GetTransactions(ctx.Request.Context(), uint(accountID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -159,26 +282,32 @@ func (c *AccountController) GetAccountTransactions(ctx *gin.Context) {
 }
 
 type DepositRequest struct {
-	Amount      float64 `json:"amount"`
-	Description string  `json:"description"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Description string  `json:"description" binding:"max=255"`
+}
+
+// WithdrawRequest names the customers giving the instruction, checked against the operating mandate
+type WithdrawRequest struct {
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Description string  `json:"description" binding:"max=255"`
+	CustomerIDs []uint  `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
 }
 
 func (c *AccountController) Deposit(ctx *gin.Context) {
 	accountID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
 	var req DepositRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	txRecord, err := c.service.Deposit(uint(accountID), req.Amount, req.Description)
+	txRecord, err := c.service.Deposit(ctx.Request.Context(), uint(accountID), req.Amount, req.Description)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -188,22 +317,126 @@ func (c *AccountController) Deposit(ctx *gin.Context) {
 func (c *AccountController) Withdraw(ctx *gin.Context) {
 	accountID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
 		return
 	}
 
-	var req DepositRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req WithdrawRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	txRecord, err := c.service.Withdraw(uint(accountID), req.Amount, req.Description)
+	txRecord, err := c.service.Withdraw(ctx.Request.Context(), uint(accountID), req.Amount, req.Description, req.CustomerIDs)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, txRecord)
 }
 
+// TransferRequest names the customers giving the instruction, checked against the source
+// account's operating mandate
+type TransferRequest struct {
+	DestinationAccountID uint    `json:"destination_account_id" binding:"required"`
+	Amount               float64 `json:"amount" binding:"required,gt=0"`
+	Description          string  `json:"description" binding:"max=255"`
+	CustomerIDs          []uint  `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+func (c *AccountController) Transfer(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	var req TransferRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	result, err := c.service.Transfer(ctx.Request.Context(), uint(accountID), req.DestinationAccountID, req.Amount, req.Description, req.CustomerIDs)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+type AccountStatusRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+func (c *AccountController) FreezeAccount(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.Freeze)
+}
+
+func (c *AccountController) UnfreezeAccount(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.Unfreeze)
+}
+
+func (c *AccountController) ReactivateAccount(ctx *gin.Context) {
+	c.changeStatus(ctx, c.service.Reactivate)
+}
+
+func (c *AccountController) changeStatus(ctx *gin.Context, change func(context.Context, uint, string) (*models.Account, error)) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	var req AccountStatusRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	account, err := change(ctx.Request.Context(), uint(accountID), req.Reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, account.Version)
+	ctx.JSON(http.StatusOK, account)
+}
+
+func (c *AccountController) CloseAccount(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	var req AccountStatusRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	account, payout, err := c.service.Close(ctx.Request.Context(), uint(accountID), req.Reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, account.Version)
+	ctx.JSON(http.StatusOK, gin.H{"account": account, "payout": payout})
+}
+
+func (c *AccountController) GetAccountStatusHistory(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	history, err := c.service.GetStatusHistory(ctx.Request.Context(), uint(accountID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
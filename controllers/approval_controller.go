@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApprovalController struct {
+	service *services.ApprovalService
+}
+
+func NewApprovalController(service *services.ApprovalService) *ApprovalController {
+	return &ApprovalController{service: service}
+}
+
+type ApproveRequest struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+type RejectRequest struct {
+	Note string `json:"note" binding:"required,max=255"`
+}
+
+func (c *ApprovalController) GetAllApprovals(ctx *gin.Context) {
+	status := ctx.Query("status")
+	switch status {
+	case "", "pending", "approved", "executed", "failed", "rejected":
+	default:
+		respondError(ctx, services.ValidationError("invalid_status", "status must be one of pending, approved, executed, failed, rejected"))
+		return
+	}
+
+	requests, err := c.service.GetAll(ctx.Request.Context(), status)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, requests)
+}
+
+func (c *ApprovalController) GetApprovalByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid approval id"))
+		return
+	}
+
+	request, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
+
+func (c *ApprovalController) Approve(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid approval id"))
+		return
+	}
+
+	var req ApproveRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	request, err := c.service.Approve(ctx.Request.Context(), uint(id), req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
+
+func (c *ApprovalController) Reject(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid approval id"))
+		return
+	}
+
+	var req RejectRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	request, err := c.service.Reject(ctx.Request.Context(), uint(id), req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, request)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"banking_system/identifiers"
 	"banking_system/models"
 	"banking_system/services"
 
@@ -18,45 +19,73 @@ func NewBankController(service *services.BankService) *BankController {
 	return &BankController{service: service}
 }
 
+type CreateBankRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Code     string `json:"code" binding:"required,bank_code"`
+	Location string `json:"location" binding:"required,max=120"`
+}
+
+type UpdateBankRequest struct {
+	Name     *string `json:"name" binding:"omitnil,min=1,max=100"`
+	Code     *string `json:"code" binding:"omitnil,bank_code"`
+	Location *string `json:"location" binding:"omitnil,min=1,max=120"`
+}
+
+func (r UpdateBankRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Name != nil {
+		changes["name"] = *r.Name
+	}
+	if r.Code != nil {
+		changes["code"] = identifiers.Normalize(*r.Code)
+	}
+	if r.Location != nil {
+		changes["location"] = *r.Location
+	}
+	return changes
+}
+
 func (c *BankController) CreateBank(ctx *gin.Context) {
-	var bank models.Bank
-	if err := ctx.ShouldBindJSON(&bank); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateBankRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if bank.Name == "" || bank.Code == "" || bank.Location == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name, code, and location are required"})
-		return
+	bank := models.Bank{
+		Name:     req.Name,
+		Code:     identifiers.Normalize(req.Code),
+		Location: req.Location,
 	}
 
-	if err := c.service.Create(&bank); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &bank); err != nil {
+		respondError(ctx, err)
 		return
 	}
+	setETag(ctx, bank.Version)
 	ctx.JSON(http.StatusCreated, bank)
 }
 
 func (c *BankController) GetBankByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid bank id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid bank id"))
 		return
 	}
 
-	bank, err := c.service.GetByID(uint(id))
+	bank, err := c.service.GetByID(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "bank not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, bank.Version)
 	ctx.JSON(http.StatusOK, bank)
 }
 
 func (c *BankController) GetAllBanks(ctx *gin.Context) {
-	banks, err := c.service.GetAll()
+	banks, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, banks)
@@ -65,46 +94,46 @@ func (c *BankController) GetAllBanks(ctx *gin.Context) {
 func (c *BankController) UpdateBank(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid bank id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid bank id"))
 		return
 	}
 
-	bank, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "bank not found"})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := ctx.ShouldBindJSON(bank); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req UpdateBankRequest
+	if !bindPatch(ctx, &req) {
 		return
 	}
 
-	if bank.Name == "" || bank.Code == "" || bank.Location == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name, code, and location are required"})
-		return
-	}
-
-	if err := c.service.Update(bank); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	bank, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, bank.Version)
 	ctx.JSON(http.StatusOK, bank)
 }
 
 func (c *BankController) DeleteBank(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid bank id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid bank id"))
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusNoContent)
 }
-
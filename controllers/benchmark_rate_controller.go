@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BenchmarkRateController struct {
+	service *services.BenchmarkRateService
+}
+
+func NewBenchmarkRateController(service *services.BenchmarkRateService) *BenchmarkRateController {
+	return &BenchmarkRateController{service: service}
+}
+
+type CreateBenchmarkRateRequest struct {
+	Code          string     `json:"code" binding:"required,max=20"`
+	Name          string     `json:"name" binding:"required,max=100"`
+	Rate          float64    `json:"rate" binding:"gte=0,lte=100"`
+	EffectiveFrom *time.Time `json:"effective_from"`
+}
+
+// changing the rate reprices the ongoing floating loans linked to the benchmark
+type UpdateBenchmarkRateRequest struct {
+	Name *string  `json:"name" binding:"omitnil,min=1,max=100"`
+	Rate *float64 `json:"rate" binding:"omitnil,gte=0,lte=100"`
+}
+
+func (r UpdateBenchmarkRateRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Name != nil {
+		changes["name"] = *r.Name
+	}
+	if r.Rate != nil {
+		changes["rate"] = *r.Rate
+	}
+	return changes
+}
+
+func (c *BenchmarkRateController) CreateBenchmarkRate(ctx *gin.Context) {
+	var req CreateBenchmarkRateRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	benchmark := models.BenchmarkRate{
+		Code: req.Code,
+		Name: req.Name,
+		Rate: req.Rate,
+	}
+	if req.EffectiveFrom != nil {
+		benchmark.EffectiveFrom = *req.EffectiveFrom
+	}
+
+	if err := c.service.Create(ctx.Request.Context(), &benchmark); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, benchmark.Version)
+	ctx.JSON(http.StatusCreated, benchmark)
+}
+
+func (c *BenchmarkRateController) GetBenchmarkRateByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid benchmark rate id"))
+		return
+	}
+
+	benchmark, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, benchmark.Version)
+	ctx.JSON(http.StatusOK, benchmark)
+}
+
+func (c *BenchmarkRateController) GetAllBenchmarkRates(ctx *gin.Context) {
+	benchmarks, err := c.service.GetAll(ctx.Request.Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, benchmarks)
+}
+
+func (c *BenchmarkRateController) UpdateBenchmarkRate(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid benchmark rate id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateBenchmarkRateRequest
+	if !bindPatch(ctx, &req) {
+		return
+	}
+
+	benchmark, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, benchmark.Version)
+	ctx.JSON(http.StatusOK, benchmark)
+}
+
+func (c *BenchmarkRateController) DeleteBenchmarkRate(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid benchmark rate id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
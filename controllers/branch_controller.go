@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"banking_system/identifiers"
 	"banking_system/models"
 	"banking_system/services"
 
@@ -18,40 +19,89 @@ func NewBranchController(service *services.BranchService) *BranchController {
 	return &BranchController{service: service}
 }
 
+type CreateBranchRequest struct {
+	Name    string `json:"branch_name" binding:"required,max=100"`
+	Code    string `json:"code" binding:"required,routing_code"`
+	BankID  uint   `json:"bank_id" binding:"required"`
+	Manager string `json:"branch_manager" binding:"max=120"`
+}
+
+type UpdateBranchRequest struct {
+	Name    *string `json:"branch_name" binding:"omitnil,min=1,max=100"`
+	Code    *string `json:"code" binding:"omitnil,routing_code"`
+	BankID  *uint   `json:"bank_id" binding:"omitnil,gt=0"`
+	Manager *string `json:"branch_manager" binding:"omitnil,max=120"`
+}
+
+func (r UpdateBranchRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Name != nil {
+		changes["name"] = *r.Name
+	}
+	if r.Code != nil {
+		changes["code"] = identifiers.Normalize(*r.Code)
+	}
+	if r.BankID != nil {
+		changes["bank_id"] = *r.BankID
+	}
+	if r.Manager != nil {
+		changes["manager"] = *r.Manager
+	}
+	return changes
+}
+
 func (c *BranchController) CreateBranch(ctx *gin.Context) {
-	var branch models.Branch
-	if err := ctx.ShouldBindJSON(&branch); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateBranchRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if err := c.service.Create(&branch); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	branch := models.Branch{
+		Name:    req.Name,
+		Code:    identifiers.Normalize(req.Code),
+		BankID:  req.BankID,
+		Manager: req.Manager,
+	}
+
+	if err := c.service.Create(ctx.Request.Context(), &branch); err != nil {
+		respondError(ctx, err)
 		return
 	}
+	setETag(ctx, branch.Version)
 	ctx.JSON(http.StatusCreated, branch)
 }
 
 func (c *BranchController) GetBranchByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid branch id"))
 		return
 	}
 
-	branch, err := c.service.GetByID(uint(id))
+	branch, err := c.service.GetByID(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, branch.Version)
 	ctx.JSON(http.StatusOK, branch)
 }
 
+func (c *BranchController) GetBranchByRoutingCode(ctx *gin.Context) {
+	routing, err := c.service.GetByRoutingCode(ctx.Request.Context(), ctx.Param("code"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, routing)
+}
+
 func (c *BranchController) GetAllBranches(ctx *gin.Context) {
-	branches, err := c.service.GetAll()
+	branches, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, branches)
@@ -60,41 +110,46 @@ func (c *BranchController) GetAllBranches(ctx *gin.Context) {
 func (c *BranchController) UpdateBranch(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid branch id"))
 		return
 	}
 
-	branch, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "branch not found"})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := ctx.ShouldBindJSON(branch); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req UpdateBranchRequest
+	if !bindPatch(ctx, &req) {
 		return
 	}
 
-	if err := c.service.Update(branch); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	branch, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, branch.Version)
 	ctx.JSON(http.StatusOK, branch)
 }
 
 func (c *BranchController) DeleteBranch(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid branch id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid branch id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusNoContent)
 }
-
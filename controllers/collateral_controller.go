@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CollateralController struct {
+	service *services.CollateralService
+}
+
+func NewCollateralController(service *services.CollateralService) *CollateralController {
+	return &CollateralController{service: service}
+}
+
+type CollateralRequest struct {
+	Type          string     `json:"collateral_type" binding:"required,oneof=property vehicle deposit securities gold"`
+	Description   string     `json:"description" binding:"max=255"`
+	Valuation     float64    `json:"valuation" binding:"required,gt=0"`
+	ValuationDate *time.Time `json:"valuation_date"`
+}
+
+func (r CollateralRequest) collateral() models.Collateral {
+	collateral := models.Collateral{
+		Type:        r.Type,
+		Description: r.Description,
+		Valuation:   r.Valuation,
+	}
+	if r.ValuationDate != nil {
+		collateral.ValuationDate = *r.ValuationDate
+	}
+	return collateral
+}
+
+type RevalueCollateralRequest struct {
+	Description   *string    `json:"description" binding:"omitnil,max=255"`
+	Valuation     *float64   `json:"valuation" binding:"omitnil,gt=0"`
+	ValuationDate *time.Time `json:"valuation_date"`
+}
+
+func (r RevalueCollateralRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Description != nil {
+		changes["description"] = *r.Description
+	}
+	if r.Valuation != nil {
+		changes["valuation"] = *r.Valuation
+		//a new valuation without a date is taken as of today
+		changes["valuation_date"] = time.Now()
+	}
+	if r.ValuationDate != nil {
+		changes["valuation_date"] = *r.ValuationDate
+	}
+	return changes
+}
+
+func (c *CollateralController) PledgeCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	var req CollateralRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	collateral := req.collateral()
+	if err := c.service.Pledge(ctx.Request.Context(), uint(id), &collateral); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, collateral.Version)
+	ctx.JSON(http.StatusCreated, collateral)
+}
+
+func (c *CollateralController) GetApplicationCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	collateral, err := c.service.GetForApplication(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, collateral)
+}
+
+func (c *CollateralController) AddLoanCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	var req CollateralRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	collateral := req.collateral()
+	if err := c.service.AddToLoan(ctx.Request.Context(), uint(id), &collateral); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, collateral.Version)
+	ctx.JSON(http.StatusCreated, collateral)
+}
+
+func (c *CollateralController) GetLoanCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	collateral, err := c.service.GetForLoan(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, collateral)
+}
+
+func (c *CollateralController) RevalueCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+	collateralID, err := strconv.Atoi(ctx.Param("collateralId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid collateral id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	var req RevalueCollateralRequest
+	if !bindPatch(ctx, &req) {
+		return
+	}
+
+	collateral, err := c.service.Revalue(ctx.Request.Context(), uint(id), uint(collateralID), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, collateral.Version)
+	ctx.JSON(http.StatusOK, collateral)
+}
+
+func (c *CollateralController) ReleaseCollateral(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+	collateralID, err := strconv.Atoi(ctx.Param("collateralId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid collateral id"))
+		return
+	}
+
+	collateral, err := c.service.Release(ctx.Request.Context(), uint(id), uint(collateralID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, collateral.Version)
+	ctx.JSON(http.StatusOK, collateral)
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"banking_system/models"
 	"banking_system/services"
@@ -18,46 +19,91 @@ func NewCustomerController(service *services.CustomerService) *CustomerControlle
 	return &CustomerController{service: service}
 }
 
+type CreateCustomerRequest struct {
+	FirstName   string     `json:"first_name" binding:"required,max=100"`
+	LastName    string     `json:"last_name" binding:"required,max=100"`
+	Email       string     `json:"email" binding:"required,email,max=150"`
+	Phone       string     `json:"phone_number" binding:"required,phone"`
+	DateOfBirth *time.Time `json:"date_of_birth" binding:"omitnil,lt"`
+}
+
+type UpdateCustomerRequest struct {
+	FirstName   *string    `json:"first_name" binding:"omitnil,min=1,max=100"`
+	LastName    *string    `json:"last_name" binding:"omitnil,min=1,max=100"`
+	Email       *string    `json:"email" binding:"omitnil,email,max=150"`
+	Phone       *string    `json:"phone_number" binding:"omitnil,phone"`
+	DateOfBirth *time.Time `json:"date_of_birth" binding:"omitnil,lt"`
+}
+
+type MergeCustomerRequest struct {
+	DuplicateID uint   `json:"duplicate_id" binding:"required"`
+	Reason      string `json:"reason" binding:"max=255"`
+}
+
+func (r UpdateCustomerRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.FirstName != nil {
+		changes["first_name"] = *r.FirstName
+	}
+	if r.LastName != nil {
+		changes["last_name"] = *r.LastName
+	}
+	if r.Email != nil {
+		changes["email"] = *r.Email
+	}
+	if r.Phone != nil {
+		changes["phone"] = *r.Phone
+	}
+	if r.DateOfBirth != nil {
+		changes["date_of_birth"] = *r.DateOfBirth
+	}
+	return changes
+}
+
 func (c *CustomerController) CreateCustomer(ctx *gin.Context) {
-	var customer models.Customer
-	if err := ctx.ShouldBindJSON(&customer); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateCustomerRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if customer.FirstName == "" || customer.LastName == "" || customer.Email == "" || customer.Phone == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "first_name, last_name, email, and phone_number are required"})
-		return
+	customer := models.Customer{
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		Phone:       req.Phone,
+		DateOfBirth: req.DateOfBirth,
 	}
 
-	if err := c.service.Create(&customer); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Create(ctx.Request.Context(), &customer); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, customer.Version)
 	ctx.JSON(http.StatusCreated, customer)
 }
 
 func (c *CustomerController) GetCustomerByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	customer, err := c.service.GetByID(uint(id))
+	customer, err := c.service.GetByID(ctx.Request.Context(), uint(id), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, customer.Version)
 	ctx.JSON(http.StatusOK, customer)
 }
 
 func (c *CustomerController) GetAllCustomers(ctx *gin.Context) {
-	customers, err := c.service.GetAll()
+	customers, err := c.service.GetAll(ctx.Request.Context(), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, customers)
@@ -66,59 +112,77 @@ func (c *CustomerController) GetAllCustomers(ctx *gin.Context) {
 func (c *CustomerController) UpdateCustomer(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateCustomerRequest
+	if !bindPatch(ctx, &req) {
 		return
 	}
 
-	customer, err := c.service.GetByID(uint(id))
+	customer, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+		respondError(ctx, err)
 		return
 	}
 
-	if err := ctx.ShouldBindJSON(customer); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	setETag(ctx, customer.Version)
+	ctx.JSON(http.StatusOK, customer)
+}
+
+func (c *CustomerController) DeleteCustomer(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	if customer.FirstName == "" || customer.LastName == "" || customer.Email == "" || customer.Phone == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "first_name, last_name, email, and phone_number are required"})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := c.service.Update(customer); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, customer)
+	ctx.Status(http.StatusNoContent)
 }
 
-func (c *CustomerController) DeleteCustomer(ctx *gin.Context) {
+func (c *CustomerController) RestoreCustomer(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	customer, err := c.service.Restore(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusNoContent)
+	setETag(ctx, customer.Version)
+	ctx.JSON(http.StatusOK, customer)
 }
 
 func (c *CustomerController) GetCustomerAccounts(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	accounts, err := c.service.GetAccounts(uint(id))
+	accounts, err := c.service.GetAccounts(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -128,16 +192,81 @@ func (c *CustomerController) GetCustomerAccounts(ctx *gin.Context) {
 func (c *CustomerController) GetCustomerLoans(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
 		return
 	}
 
-	loans, err := c.service.GetLoans(uint(id))
+	loans, err := c.service.GetLoansAndGuarantees(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, loans)
 }
 
+// FindDuplicateCustomers lists likely duplicate pairs, optionally only those involving
+// ?customer_id= and scoring at least ?min_score=.
+func (c *CustomerController) FindDuplicateCustomers(ctx *gin.Context) {
+	var filter services.DuplicateFilter
+	if raw := ctx.Query("customer_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+			return
+		}
+		filter.CustomerID = uint(id)
+	}
+	if raw := ctx.Query("min_score"); raw != "" {
+		score, err := strconv.ParseFloat(raw, 64)
+		if err != nil || score <= 0 || score > 1 {
+			respondError(ctx, services.ValidationError("invalid_min_score", "min_score must be a number above 0 and at most 1"))
+			return
+		}
+		filter.MinScore = score
+	}
+
+	matches, err := c.service.FindDuplicates(ctx.Request.Context(), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, matches)
+}
+
+// MergeCustomer folds the duplicate_id customer into the one in the path.
+func (c *CustomerController) MergeCustomer(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req MergeCustomerRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	merge, err := c.service.Merge(ctx.Request.Context(), uint(id), req.DuplicateID, req.Reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, merge)
+}
+
+func (c *CustomerController) GetCustomerMerges(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	merges, err := c.service.GetMerges(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, merges)
+}
+
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DepositController struct {
+	service *services.DepositService
+}
+
+func NewDepositController(service *services.DepositService) *DepositController {
+	return &DepositController{service: service}
+}
+
+// FixedDepositRequest names the customers giving the instruction, checked against the funding
+// account's operating mandate
+type FixedDepositRequest struct {
+	AccountID           uint    `json:"account_id" binding:"required"`
+	Principal           float64 `json:"principal" binding:"required,gt=0"`
+	TenureMonths        int     `json:"tenure_months" binding:"required,gt=0,lte=120"`
+	Compounding         string  `json:"compounding" binding:"omitempty,oneof=monthly quarterly half_yearly yearly"`
+	MaturityInstruction string  `json:"maturity_instruction" binding:"omitempty,oneof=payout auto_renew"`
+	CustomerIDs         []uint  `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+type MaturityInstructionRequest struct {
+	MaturityInstruction string `json:"maturity_instruction" binding:"required,oneof=payout auto_renew"`
+	CustomerIDs         []uint `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+type RecurringDepositRequest struct {
+	AccountID         uint    `json:"account_id" binding:"required"`
+	InstallmentAmount float64 `json:"installment_amount" binding:"required,gt=0"`
+	TenureMonths      int     `json:"tenure_months" binding:"required,gt=0,lte=120"`
+	Compounding       string  `json:"compounding" binding:"omitempty,oneof=monthly quarterly half_yearly yearly"`
+	CustomerIDs       []uint  `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+func accountFilter(ctx *gin.Context) (uint, bool) {
+	raw := ctx.Query("account_id")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (c *DepositController) OpenFixedDeposit(ctx *gin.Context) {
+	var req FixedDepositRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	deposit := models.FixedDeposit{
+		AccountID:           req.AccountID,
+		Principal:           req.Principal,
+		TenureMonths:        req.TenureMonths,
+		Compounding:         req.Compounding,
+		MaturityInstruction: req.MaturityInstruction,
+	}
+	if deposit.Compounding == "" {
+		deposit.Compounding = models.CompoundQuarterly
+	}
+	if deposit.MaturityInstruction == "" {
+		deposit.MaturityInstruction = models.MaturityPayout
+	}
+	if err := c.service.OpenFixed(ctx.Request.Context(), &deposit, req.CustomerIDs); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, deposit.Version)
+	ctx.JSON(http.StatusCreated, deposit)
+}
+
+func (c *DepositController) GetAllFixedDeposits(ctx *gin.Context) {
+	accountID, ok := accountFilter(ctx)
+	if !ok {
+		return
+	}
+
+	deposits, err := c.service.GetAllFixed(ctx.Request.Context(), accountID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, deposits)
+}
+
+func (c *DepositController) GetFixedDepositByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid fixed deposit id"))
+		return
+	}
+
+	deposit, err := c.service.GetFixed(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, deposit.Version)
+	ctx.JSON(http.StatusOK, deposit)
+}
+
+func (c *DepositController) ChangeMaturityInstruction(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid fixed deposit id"))
+		return
+	}
+
+	var req MaturityInstructionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	deposit, err := c.service.ChangeMaturityInstruction(ctx.Request.Context(), uint(id), req.MaturityInstruction, req.CustomerIDs)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, deposit.Version)
+	ctx.JSON(http.StatusOK, deposit)
+}
+
+func (c *DepositController) OpenRecurringDeposit(ctx *gin.Context) {
+	var req RecurringDepositRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	deposit := models.RecurringDeposit{
+		AccountID:         req.AccountID,
+		InstallmentAmount: req.InstallmentAmount,
+		TenureMonths:      req.TenureMonths,
+		Compounding:       req.Compounding,
+	}
+	if deposit.Compounding == "" {
+		deposit.Compounding = models.CompoundQuarterly
+	}
+	if err := c.service.OpenRecurring(ctx.Request.Context(), &deposit, req.CustomerIDs); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, deposit.Version)
+	ctx.JSON(http.StatusCreated, deposit)
+}
+
+func (c *DepositController) GetAllRecurringDeposits(ctx *gin.Context) {
+	accountID, ok := accountFilter(ctx)
+	if !ok {
+		return
+	}
+
+	deposits, err := c.service.GetAllRecurring(ctx.Request.Context(), accountID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, deposits)
+}
+
+func (c *DepositController) GetRecurringDepositByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid recurring deposit id"))
+		return
+	}
+
+	deposit, err := c.service.GetRecurring(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, deposit.Version)
+	ctx.JSON(http.StatusOK, deposit)
+}
+
+func (c *DepositController) GetRecurringDepositInstallments(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid recurring deposit id"))
+		return
+	}
+
+	installments, err := c.service.GetInstallments(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, installments)
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setETag(ctx *gin.Context, version uint) {
+	ctx.Header("ETag", fmt.Sprintf("%q", strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatchVersion reads the version the client last saw from If-Match. "*" matches any current
+// version and is returned as 0, which services treat as unconditional.
+func ifMatchVersion(ctx *gin.Context) (uint, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		respondError(ctx, services.PreconditionRequiredError("if_match_required", "If-Match header with the resource ETag is required"))
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version == 0 || strings.HasPrefix(header, "W/") {
+		respondError(ctx, services.ValidationError("invalid_if_match", "If-Match must be a single ETag returned by this API"))
+		return 0, false
+	}
+	return uint(version), true
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FraudAlertController struct {
+	service *services.FraudService
+}
+
+func NewFraudAlertController(service *services.FraudService) *FraudAlertController {
+	return &FraudAlertController{service: service}
+}
+
+type AssignAlertRequest struct {
+	Assignee string `json:"assignee" binding:"max=100"`
+}
+
+type ResolveAlertRequest struct {
+	Outcome string `json:"outcome" binding:"required,oneof=confirmed dismissed"`
+	Note    string `json:"note" binding:"required,max=255"`
+}
+
+func (c *FraudAlertController) GetAllFraudAlerts(ctx *gin.Context) {
+	filter := services.AlertFilter{Status: ctx.Query("status"), Severity: ctx.Query("severity")}
+	switch filter.Status {
+	case "", models.AlertOpen, models.AlertInvestigating, models.AlertConfirmed, models.AlertDismissed:
+	default:
+		respondError(ctx, services.ValidationError("invalid_status", "status must be one of open, investigating, confirmed, dismissed"))
+		return
+	}
+	switch filter.Severity {
+	case "", models.SeverityLow, models.SeverityMedium, models.SeverityHigh, models.SeverityCritical:
+	default:
+		respondError(ctx, services.ValidationError("invalid_severity", "severity must be one of low, medium, high, critical"))
+		return
+	}
+	accountID, ok := accountFilter(ctx)
+	if !ok {
+		return
+	}
+	filter.AccountID = accountID
+
+	alerts, err := c.service.GetAll(ctx.Request.Context(), filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, alerts)
+}
+
+func (c *FraudAlertController) GetFraudAlertByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid fraud alert id"))
+		return
+	}
+
+	alert, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, alert.Version)
+	ctx.JSON(http.StatusOK, alert)
+}
+
+func (c *FraudAlertController) AssignFraudAlert(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid fraud alert id"))
+		return
+	}
+
+	var req AssignAlertRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	alert, err := c.service.Assign(ctx.Request.Context(), uint(id), req.Assignee)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, alert.Version)
+	ctx.JSON(http.StatusOK, alert)
+}
+
+func (c *FraudAlertController) ResolveFraudAlert(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid fraud alert id"))
+		return
+	}
+
+	var req ResolveAlertRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	alert, err := c.service.Resolve(ctx.Request.Context(), uint(id), req.Outcome, req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, alert.Version)
+	ctx.JSON(http.StatusOK, alert)
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GuarantorController struct {
+	service *services.GuarantorService
+}
+
+func NewGuarantorController(service *services.GuarantorService) *GuarantorController {
+	return &GuarantorController{service: service}
+}
+
+type GuarantorRequest struct {
+	CustomerID      uint    `json:"customer_id" binding:"required,gt=0"`
+	GuaranteeAmount float64 `json:"guarantee_amount" binding:"required,gt=0"`
+}
+
+func (c *GuarantorController) OfferGuarantor(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	var req GuarantorRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	guarantor := models.LoanGuarantor{CustomerID: req.CustomerID, GuaranteeAmount: req.GuaranteeAmount}
+	if err := c.service.Offer(ctx.Request.Context(), uint(id), &guarantor); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, guarantor)
+}
+
+func (c *GuarantorController) GetApplicationGuarantors(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	guarantors, err := c.service.GetForApplication(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, guarantors)
+}
+
+func (c *GuarantorController) AddLoanGuarantor(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	var req GuarantorRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	guarantor := models.LoanGuarantor{CustomerID: req.CustomerID, GuaranteeAmount: req.GuaranteeAmount}
+	if err := c.service.AddToLoan(ctx.Request.Context(), uint(id), &guarantor); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, guarantor)
+}
+
+func (c *GuarantorController) GetLoanGuarantors(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	guarantors, err := c.service.GetForLoan(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, guarantors)
+}
+
+func (c *GuarantorController) ReleaseGuarantor(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+	guarantorID, err := strconv.Atoi(ctx.Param("guarantorId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid guarantor id"))
+		return
+	}
+
+	guarantor, err := c.service.Release(ctx.Request.Context(), uint(id), uint(guarantorID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, guarantor)
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	service *services.HealthService
+}
+
+func NewHealthController(service *services.HealthService) *HealthController {
+	return &HealthController{service: service}
+}
+
+func (c *HealthController) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (c *HealthController) Readiness(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	status := http.StatusOK
+	checks := gin.H{"database": "ok", "migrations": "ok"}
+
+	if err := c.service.PingDatabase(checkCtx); err != nil {
+		status = http.StatusServiceUnavailable
+		checks["database"] = err.Error()
+		checks["migrations"] = "skipped"
+	} else if err := c.service.CheckMigrations(checkCtx); err != nil {
+		status = http.StatusServiceUnavailable
+		checks["migrations"] = err.Error()
+	}
+
+	result := "ok"
+	if status != http.StatusOK {
+		result = "unavailable"
+	}
+	ctx.JSON(status, gin.H{"status": result, "checks": checks})
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KYCController struct {
+	service *services.KYCService
+}
+
+func NewKYCController(service *services.KYCService) *KYCController {
+	return &KYCController{service: service}
+}
+
+type AddressRequest struct {
+	Type       string `json:"address_type" binding:"required,oneof=residential permanent mailing"`
+	Line1      string `json:"line1" binding:"required,max=255"`
+	Line2      string `json:"line2" binding:"max=255"`
+	City       string `json:"city" binding:"required,max=100"`
+	State      string `json:"state" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"required,max=20"`
+	Country    string `json:"country" binding:"required,iso3166_1_alpha2"`
+}
+
+type IdentityRequest struct {
+	Type           string     `json:"id_type" binding:"required,oneof=passport national_id driving_license tax_id"`
+	Number         string     `json:"id_number" binding:"required,max=50"`
+	IssuingCountry string     `json:"issuing_country" binding:"required,iso3166_1_alpha2"`
+	IssuedAt       *time.Time `json:"issued_at" binding:"omitnil,lt"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+type VerifyKYCRequest struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+type RejectKYCRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+func (c *KYCController) GetKYCProfile(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	profile, err := c.service.GetProfile(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, profile)
+}
+
+func (c *KYCController) AddAddress(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req AddressRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	address := models.CustomerAddress{
+		Type:       req.Type,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		City:       req.City,
+		State:      req.State,
+		PostalCode: req.PostalCode,
+		Country:    req.Country,
+	}
+	if err := c.service.AddAddress(ctx.Request.Context(), uint(id), &address); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, address)
+}
+
+func (c *KYCController) DeleteAddress(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+	addressID, err := strconv.Atoi(ctx.Param("addressId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid address id"))
+		return
+	}
+
+	if err := c.service.DeleteAddress(ctx.Request.Context(), uint(id), uint(addressID)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *KYCController) AddIdentity(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req IdentityRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	identity := models.CustomerIdentity{
+		Type:           req.Type,
+		Number:         req.Number,
+		IssuingCountry: req.IssuingCountry,
+		IssuedAt:       req.IssuedAt,
+		ExpiresAt:      req.ExpiresAt,
+	}
+	if err := c.service.AddIdentity(ctx.Request.Context(), uint(id), &identity); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, identity)
+}
+
+// UploadDocument takes a multipart form with the file under "file", its "document_type" and
+// optionally the "identity_id" it proves.
+func (c *KYCController) UploadDocument(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	document := models.CustomerDocument{Type: ctx.PostForm("document_type")}
+	switch document.Type {
+	case models.DocumentIDProof, models.DocumentAddressProof, models.DocumentPhoto, models.DocumentOther:
+	default:
+		respondError(ctx, services.FieldValidationError(services.FieldError{Field: "document_type", Message: "must be one of id_proof, address_proof, photo, other"}))
+		return
+	}
+	if raw := ctx.PostForm("identity_id"); raw != "" {
+		identityID, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(ctx, services.FieldValidationError(services.FieldError{Field: "identity_id", Message: "must be a number"}))
+			return
+		}
+		value := uint(identityID)
+		document.IdentityID = &value
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		respondError(ctx, services.FieldValidationError(services.FieldError{Field: "file", Message: "is required"}))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	document.FileName = header.Filename
+	document.ContentType = header.Header.Get("Content-Type")
+	if document.ContentType == "" {
+		document.ContentType = "application/octet-stream"
+	}
+	if err := c.service.UploadDocument(ctx.Request.Context(), uint(id), &document, file); err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, document)
+}
+
+func (c *KYCController) DownloadDocument(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+	documentID, err := strconv.Atoi(ctx.Param("documentId"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid document id"))
+		return
+	}
+
+	document, content, err := c.service.OpenDocument(ctx.Request.Context(), uint(id), uint(documentID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	defer content.Close()
+
+	ctx.DataFromReader(http.StatusOK, document.Size, document.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": document.FileName}),
+	})
+}
+
+func (c *KYCController) VerifyKYC(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req VerifyKYCRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	customer, err := c.service.Verify(ctx.Request.Context(), uint(id), req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, customer.Version)
+	ctx.JSON(http.StatusOK, customer)
+}
+
+func (c *KYCController) RejectKYC(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+		return
+	}
+
+	var req RejectKYCRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	customer, err := c.service.Reject(ctx.Request.Context(), uint(id), req.Reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, customer.Version)
+	ctx.JSON(http.StatusOK, customer)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LimitController struct {
+	service *services.LimitService
+}
+
+func NewLimitController(service *services.LimitService) *LimitController {
+	return &LimitController{service: service}
+}
+
+// LimitsRequest replaces the whole set of limits, omitted or null fields are not limited
+type LimitsRequest struct {
+	MaxSingleWithdrawal    *float64 `json:"max_single_withdrawal" binding:"omitnil,gt=0"`
+	DailyWithdrawalTotal   *float64 `json:"daily_withdrawal_total" binding:"omitnil,gt=0"`
+	MonthlyWithdrawalTotal *float64 `json:"monthly_withdrawal_total" binding:"omitnil,gt=0"`
+	DailyWithdrawalCount   *int     `json:"daily_withdrawal_count" binding:"omitnil,gt=0"`
+}
+
+func (r LimitsRequest) limits() models.WithdrawalLimits {
+	return models.WithdrawalLimits{
+		MaxSingleWithdrawal:    r.MaxSingleWithdrawal,
+		DailyWithdrawalTotal:   r.DailyWithdrawalTotal,
+		MonthlyWithdrawalTotal: r.MonthlyWithdrawalTotal,
+		DailyWithdrawalCount:   r.DailyWithdrawalCount,
+	}
+}
+
+func (c *LimitController) GetAccountLimits(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	limits, err := c.service.GetForAccount(ctx.Request.Context(), uint(accountID))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, limits)
+}
+
+func (c *LimitController) SetAccountLimits(ctx *gin.Context) {
+	accountID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid account id"))
+		return
+	}
+
+	var req LimitsRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	limit, err := c.service.SetForAccount(ctx.Request.Context(), uint(accountID), req.limits())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, limit.Version)
+	ctx.JSON(http.StatusOK, limit)
+}
+
+func accountTypeParam(ctx *gin.Context) (string, bool) {
+	accountType := ctx.Param("type")
+	switch accountType {
+	case "savings", "current":
+		return accountType, true
+	}
+	respondError(ctx, services.ValidationError("invalid_account_type", "account type must be savings or current"))
+	return "", false
+}
+
+func (c *LimitController) GetAccountTypeLimits(ctx *gin.Context) {
+	accountType, ok := accountTypeParam(ctx)
+	if !ok {
+		return
+	}
+
+	limit, err := c.service.GetForAccountType(ctx.Request.Context(), accountType)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, limit.Version)
+	ctx.JSON(http.StatusOK, limit)
+}
+
+func (c *LimitController) SetAccountTypeLimits(ctx *gin.Context) {
+	accountType, ok := accountTypeParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req LimitsRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	limit, err := c.service.SetForAccountType(ctx.Request.Context(), accountType, req.limits())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, limit.Version)
+	ctx.JSON(http.StatusOK, limit)
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoanApplicationController struct {
+	service *services.LoanApplicationService
+}
+
+func NewLoanApplicationController(service *services.LoanApplicationService) *LoanApplicationController {
+	return &LoanApplicationController{service: service}
+}
+
+// the rate comes from the product; eligibility, status and the decision are server-owned
+type CreateLoanApplicationRequest struct {
+	CustomerID      uint    `json:"customer_id" binding:"required"`
+	AccountID       uint    `json:"account_id" binding:"required"`
+	ProductID       uint    `json:"product_id" binding:"required"`
+	RequestedAmount float64 `json:"requested_amount" binding:"required,gt=0"`
+	TermMonths      int     `json:"term_months" binding:"required,gt=0,lte=480"`
+	MonthlyIncome   float64 `json:"monthly_income" binding:"required,gt=0"`
+	Purpose         string  `json:"purpose" binding:"max=255"`
+}
+
+func (c *LoanApplicationController) CreateLoanApplication(ctx *gin.Context) {
+	var req CreateLoanApplicationRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	application := models.LoanApplication{
+		CustomerID:      req.CustomerID,
+		AccountID:       req.AccountID,
+		ProductID:       req.ProductID,
+		RequestedAmount: req.RequestedAmount,
+		TermMonths:      req.TermMonths,
+		MonthlyIncome:   req.MonthlyIncome,
+		Purpose:         req.Purpose,
+	}
+
+	if err := c.service.Apply(ctx.Request.Context(), &application); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, application)
+}
+
+func (c *LoanApplicationController) GetAllLoanApplications(ctx *gin.Context) {
+	status := ctx.Query("status")
+	switch status {
+	case "", models.LoanApplicationSubmitted, models.LoanApplicationAwaitingApproval, models.LoanApplicationApproved, models.LoanApplicationRejected:
+	default:
+		respondError(ctx, services.ValidationError("invalid_status", "status must be one of submitted, awaiting_approval, approved, rejected"))
+		return
+	}
+
+	var customerID uint
+	if raw := ctx.Query("customer_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(ctx, services.ValidationError("invalid_id", "invalid customer id"))
+			return
+		}
+		customerID = uint(id)
+	}
+
+	applications, err := c.service.GetAll(ctx.Request.Context(), status, customerID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, applications)
+}
+
+func (c *LoanApplicationController) GetLoanApplicationByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	application, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, application)
+}
+
+func (c *LoanApplicationController) ApproveLoanApplication(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	var req ApproveRequest
+	if ctx.Request.ContentLength != 0 && !bindJSON(ctx, &req) {
+		return
+	}
+
+	application, err := c.service.Approve(ctx.Request.Context(), uint(id), req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, application)
+}
+
+func (c *LoanApplicationController) RejectLoanApplication(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan application id"))
+		return
+	}
+
+	var req RejectRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	application, err := c.service.Reject(ctx.Request.Context(), uint(id), req.Note)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, application)
+}
@@ -5,7 +5,6 @@ import (
 	"strconv"
 	"time"
 
-	"banking_system/models"
 	"banking_system/services"
 
 	"github.com/gin-gonic/gin"
@@ -19,97 +18,79 @@ func NewLoanController(service *services.LoanService) *LoanController {
 	return &LoanController{service: service}
 }
 
-func (c *LoanController) CreateLoan(ctx *gin.Context) {
-	var loan models.Loan
-	if err := ctx.ShouldBindJSON(&loan); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.service.Create(&loan); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusCreated, loan)
-}
-
 func (c *LoanController) GetLoanByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid loan id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
 		return
 	}
 
-	loan, err := c.service.GetByID(uint(id))
+	loan, err := c.service.GetByID(ctx.Request.Context(), uint(id), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "loan not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, loan.Version)
 	ctx.JSON(http.StatusOK, loan)
 }
 
 func (c *LoanController) GetAllLoans(ctx *gin.Context) {
-	loans, err := c.service.GetAll()
+	loans, err := c.service.GetAll(ctx.Request.Context(), includeDeleted(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, loans)
 }
 
-func (c *LoanController) UpdateLoan(ctx *gin.Context) {
+func (c *LoanController) DeleteLoan(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid loan id"})
-		return
-	}
-
-	loan, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "loan not found"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
 		return
 	}
 
-	if err := ctx.ShouldBindJSON(loan); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
-	if err := c.service.Update(loan); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, loan)
+	ctx.Status(http.StatusNoContent)
 }
 
-func (c *LoanController) DeleteLoan(ctx *gin.Context) {
+func (c *LoanController) RestoreLoan(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid loan id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
 		return
 	}
 
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	loan, err := c.service.Restore(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.Status(http.StatusNoContent)
+	setETag(ctx, loan.Version)
+	ctx.JSON(http.StatusOK, loan)
 }
 
 func (c *LoanController) GetLoanDetails(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid loan id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
 		return
 	}
 
-	details, err := c.service.GetDetails(uint(id))
+	details, err := c.service.GetDetails(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -117,20 +98,20 @@ func (c *LoanController) GetLoanDetails(ctx *gin.Context) {
 }
 
 type RepayRequest struct {
-	Amount      float64 `json:"amount"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
 	PaymentDate string  `json:"payment_date"`
+	Overpayment string  `json:"overpayment" binding:"omitempty,oneof=refund advance"`
 }
 
 func (c *LoanController) RepayLoan(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid loan id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
 		return
 	}
 
 	var req RepayRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
@@ -140,17 +121,151 @@ func (c *LoanController) RepayLoan(ctx *gin.Context) {
 	} else {
 		paymentDate, err = time.Parse(time.RFC3339, req.PaymentDate)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid payment_date, must be RFC3339"})
+			respondError(ctx, services.ValidationError("invalid_payment_date", "invalid payment_date, must be RFC3339"))
 			return
 		}
 	}
 
-	repayment, err := c.service.Repay(uint(id), req.Amount, paymentDate)
+	repayment, err := c.service.Repay(ctx.Request.Context(), uint(id), req.Amount, paymentDate, req.Overpayment)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, repayment)
 }
 
+func (c *LoanController) GetLoanSchedule(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	schedule, err := c.service.GetSchedule(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedule)
+}
+
+func (c *LoanController) GetLoanPayoff(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	asOf := time.Now()
+	if raw := ctx.Query("as_of"); raw != "" {
+		asOf, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(ctx, services.ValidationError("invalid_as_of", "invalid as_of, must be RFC3339"))
+			return
+		}
+	}
+
+	quote, err := c.service.Payoff(ctx.Request.Context(), uint(id), asOf)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quote)
+}
+
+func (c *LoanController) ForecloseLoan(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	result, err := c.service.Foreclose(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, result.Loan.Version)
+	ctx.JSON(http.StatusOK, result)
+}
+
+type PrepayRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Mode   string  `json:"mode" binding:"required,oneof=reduce_tenure reduce_emi"`
+}
+
+func (c *LoanController) PrepayLoan(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	var req PrepayRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	result, err := c.service.Prepay(ctx.Request.Context(), uint(id), req.Amount, req.Mode)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, result.Loan.Version)
+	ctx.JSON(http.StatusOK, result)
+}
+
+type RestructureLoanRequest struct {
+	TermMonths       *int     `json:"term_months" binding:"omitnil,gt=0,lte=480"`
+	InterestRate     *float64 `json:"interest_rate" binding:"omitnil,gte=0,lte=100"`
+	MoratoriumMonths int      `json:"moratorium_months" binding:"gte=0,lte=24"`
+	Reason           string   `json:"reason" binding:"required,max=255"`
+}
+
+func (c *LoanController) RestructureLoan(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	var req RestructureLoanRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	result, err := c.service.Restructure(ctx.Request.Context(), uint(id), services.Restructuring{
+		TermMonths:       req.TermMonths,
+		InterestRate:     req.InterestRate,
+		MoratoriumMonths: req.MoratoriumMonths,
+		Reason:           req.Reason,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, result.Loan.Version)
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (c *LoanController) GetLoanTerms(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan id"))
+		return
+	}
+
+	terms, err := c.service.GetTerms(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, terms)
+}
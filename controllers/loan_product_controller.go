@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoanProductController struct {
+	service *services.LoanProductService
+}
+
+func NewLoanProductController(service *services.LoanProductService) *LoanProductController {
+	return &LoanProductController{service: service}
+}
+
+type CreateLoanProductRequest struct {
+	Code                     string  `json:"code" binding:"required,max=20"`
+	Name                     string  `json:"name" binding:"required,max=100"`
+	MinAmount                float64 `json:"min_amount" binding:"gt=0"`
+	MaxAmount                float64 `json:"max_amount" binding:"required,gt=0"`
+	MinTermMonths            int     `json:"min_term_months" binding:"required,gt=0"`
+	MaxTermMonths            int     `json:"max_term_months" binding:"required,gt=0,lte=480"`
+	RateType                 string  `json:"rate_type" binding:"required,oneof=fixed floating"`
+	FixedRate                float64 `json:"fixed_rate" binding:"gte=0,lte=100"`
+	BenchmarkID              *uint   `json:"benchmark_id" binding:"omitnil,gt=0"`
+	Spread                   float64 `json:"spread" binding:"gte=-100,lte=100"`
+	ProcessingFeePercent     float64 `json:"processing_fee_percent" binding:"gte=0,lte=100"`
+	MinProcessingFee         float64 `json:"min_processing_fee" binding:"gte=0"`
+	PrepaymentAllowed        *bool   `json:"prepayment_allowed"`
+	PrepaymentLockInMonths   int     `json:"prepayment_lock_in_months" binding:"gte=0"`
+	PrepaymentPenaltyPercent float64 `json:"prepayment_penalty_percent" binding:"gte=0,lte=100"`
+	LatePaymentFeePercent    float64 `json:"late_payment_fee_percent" binding:"gte=0,lte=100"`
+	MaxLTVPercent            float64 `json:"max_ltv_percent" binding:"gte=0,lte=100"`
+}
+
+// code, rate_type and benchmark_id define what existing loans were sold as and are fixed
+type UpdateLoanProductRequest struct {
+	Name                     *string  `json:"name" binding:"omitnil,min=1,max=100"`
+	MinAmount                *float64 `json:"min_amount" binding:"omitnil,gt=0"`
+	MaxAmount                *float64 `json:"max_amount" binding:"omitnil,gt=0"`
+	MinTermMonths            *int     `json:"min_term_months" binding:"omitnil,gt=0"`
+	MaxTermMonths            *int     `json:"max_term_months" binding:"omitnil,gt=0,lte=480"`
+	FixedRate                *float64 `json:"fixed_rate" binding:"omitnil,gte=0,lte=100"`
+	Spread                   *float64 `json:"spread" binding:"omitnil,gte=-100,lte=100"`
+	ProcessingFeePercent     *float64 `json:"processing_fee_percent" binding:"omitnil,gte=0,lte=100"`
+	MinProcessingFee         *float64 `json:"min_processing_fee" binding:"omitnil,gte=0"`
+	PrepaymentAllowed        *bool    `json:"prepayment_allowed"`
+	PrepaymentLockInMonths   *int     `json:"prepayment_lock_in_months" binding:"omitnil,gte=0"`
+	PrepaymentPenaltyPercent *float64 `json:"prepayment_penalty_percent" binding:"omitnil,gte=0,lte=100"`
+	LatePaymentFeePercent    *float64 `json:"late_payment_fee_percent" binding:"omitnil,gte=0,lte=100"`
+	MaxLTVPercent            *float64 `json:"max_ltv_percent" binding:"omitnil,gte=0,lte=100"`
+	Active                   *bool    `json:"active"`
+}
+
+func (r UpdateLoanProductRequest) changes() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if r.Name != nil {
+		changes["name"] = *r.Name
+	}
+	if r.MinAmount != nil {
+		changes["min_amount"] = *r.MinAmount
+	}
+	if r.MaxAmount != nil {
+		changes["max_amount"] = *r.MaxAmount
+	}
+	if r.MinTermMonths != nil {
+		changes["min_term_months"] = *r.MinTermMonths
+	}
+	if r.MaxTermMonths != nil {
+		changes["max_term_months"] = *r.MaxTermMonths
+	}
+	if r.FixedRate != nil {
+		changes["fixed_rate"] = *r.FixedRate
+	}
+	if r.Spread != nil {
+		changes["spread"] = *r.Spread
+	}
+	if r.ProcessingFeePercent != nil {
+		changes["processing_fee_percent"] = *r.ProcessingFeePercent
+	}
+	if r.MinProcessingFee != nil {
+		changes["min_processing_fee"] = *r.MinProcessingFee
+	}
+	if r.PrepaymentAllowed != nil {
+		changes["prepayment_allowed"] = *r.PrepaymentAllowed
+	}
+	if r.PrepaymentLockInMonths != nil {
+		changes["prepayment_lock_in_months"] = *r.PrepaymentLockInMonths
+	}
+	if r.PrepaymentPenaltyPercent != nil {
+		changes["prepayment_penalty_percent"] = *r.PrepaymentPenaltyPercent
+	}
+	if r.LatePaymentFeePercent != nil {
+		changes["late_payment_fee_percent"] = *r.LatePaymentFeePercent
+	}
+	if r.MaxLTVPercent != nil {
+		changes["max_ltv_percent"] = *r.MaxLTVPercent
+	}
+	if r.Active != nil {
+		changes["active"] = *r.Active
+	}
+	return changes
+}
+
+func (c *LoanProductController) CreateLoanProduct(ctx *gin.Context) {
+	var req CreateLoanProductRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	product := models.LoanProduct{
+		Code:                     req.Code,
+		Name:                     req.Name,
+		MinAmount:                req.MinAmount,
+		MaxAmount:                req.MaxAmount,
+		MinTermMonths:            req.MinTermMonths,
+		MaxTermMonths:            req.MaxTermMonths,
+		RateType:                 req.RateType,
+		FixedRate:                req.FixedRate,
+		BenchmarkID:              req.BenchmarkID,
+		Spread:                   req.Spread,
+		ProcessingFeePercent:     req.ProcessingFeePercent,
+		MinProcessingFee:         req.MinProcessingFee,
+		PrepaymentAllowed:        true,
+		PrepaymentLockInMonths:   req.PrepaymentLockInMonths,
+		PrepaymentPenaltyPercent: req.PrepaymentPenaltyPercent,
+		LatePaymentFeePercent:    req.LatePaymentFeePercent,
+		MaxLTVPercent:            req.MaxLTVPercent,
+		Active:                   true,
+	}
+	if req.PrepaymentAllowed != nil {
+		product.PrepaymentAllowed = *req.PrepaymentAllowed
+	}
+
+	if err := c.service.Create(ctx.Request.Context(), &product); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, product.Version)
+	ctx.JSON(http.StatusCreated, product)
+}
+
+func (c *LoanProductController) GetLoanProductByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan product id"))
+		return
+	}
+
+	product, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, product.Version)
+	ctx.JSON(http.StatusOK, product)
+}
+
+func (c *LoanProductController) GetAllLoanProducts(ctx *gin.Context) {
+	products, err := c.service.GetAll(ctx.Request.Context(), ctx.Query("active") == "true")
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, products)
+}
+
+func (c *LoanProductController) UpdateLoanProduct(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan product id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateLoanProductRequest
+	if !bindPatch(ctx, &req) {
+		return
+	}
+
+	product, err := c.service.Update(ctx.Request.Context(), uint(id), version, req.changes())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, product.Version)
+	ctx.JSON(http.StatusOK, product)
+}
+
+func (c *LoanProductController) DeleteLoanProduct(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid loan product id"))
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.service.Delete(ctx.Request.Context(), uint(id), version); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
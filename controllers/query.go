@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// includeDeleted reads ?include_deleted=true, used by auditors to see archived records.
+func includeDeleted(ctx *gin.Context) bool {
+	include, _ := strconv.ParseBool(ctx.Query("include_deleted"))
+	return include
+}
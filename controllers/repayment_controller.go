@@ -3,8 +3,8 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
-	"banking_system/models"
 	"banking_system/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,84 +18,57 @@ func NewRepaymentController(service *services.RepaymentService) *RepaymentContro
 	return &RepaymentController{service: service}
 }
 
+type CreateRepaymentRequest struct {
+	LoanID      uint       `json:"loan_id" binding:"required"`
+	Amount      float64    `json:"amount" binding:"required,gt=0"`
+	PaymentDate *time.Time `json:"repayment_date"`
+	Overpayment string     `json:"overpayment" binding:"omitempty,oneof=refund advance"`
+}
+
 func (c *RepaymentController) CreateRepayment(ctx *gin.Context) {
-	var repayment models.Repayment
-	if err := ctx.ShouldBindJSON(&repayment); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req CreateRepaymentRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	if err := c.service.Create(&repayment); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var paymentDate time.Time
+	if req.PaymentDate != nil {
+		paymentDate = *req.PaymentDate
+	}
+
+	repayment, err := c.service.Create(ctx.Request.Context(), req.LoanID, req.Amount, paymentDate, req.Overpayment)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, repayment.Version)
 	ctx.JSON(http.StatusCreated, repayment)
 }
 
 func (c *RepaymentController) GetRepaymentByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid repayment id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid repayment id"))
 		return
 	}
 
-	repayment, err := c.service.GetByID(uint(id))
+	repayment, err := c.service.GetByID(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "repayment not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, repayment.Version)
 	ctx.JSON(http.StatusOK, repayment)
 }
 
 func (c *RepaymentController) GetAllRepayments(ctx *gin.Context) {
-	repayments, err := c.service.GetAll()
+	repayments, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, repayments)
 }
 
-func (c *RepaymentController) UpdateRepayment(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid repayment id"})
-		return
-	}
-
-	repayment, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "repayment not found"})
-		return
-	}
-
-	if err := ctx.ShouldBindJSON(repayment); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.service.Update(repayment); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, repayment)
-}
-
-func (c *RepaymentController) DeleteRepayment(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid repayment id"})
-		return
-	}
-
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.Status(http.StatusNoContent)
-}
-
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError hands the error to middleware.ErrorHandler, which renders it as a problem
+// document and maps domain error kinds onto status codes. Operations parked for approval are
+// not failures and answer 202 with the pending request.
+func respondError(ctx *gin.Context, err error) {
+	var pending *services.PendingApprovalError
+	if errors.As(err, &pending) {
+		ctx.JSON(http.StatusAccepted, pending.Request)
+		return
+	}
+	_ = ctx.Error(err)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"banking_system/models"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StandingInstructionController struct {
+	service *services.StandingInstructionService
+}
+
+func NewStandingInstructionController(service *services.StandingInstructionService) *StandingInstructionController {
+	return &StandingInstructionController{service: service}
+}
+
+// StandingInstructionRequest names the customers giving the instruction, checked against the
+// source account's operating mandate now and on every execution
+type StandingInstructionRequest struct {
+	SourceAccountID      uint       `json:"source_account_id" binding:"required"`
+	DestinationAccountID uint       `json:"destination_account_id" binding:"required"`
+	Amount               float64    `json:"amount" binding:"required,gt=0"`
+	Description          string     `json:"description" binding:"max=255"`
+	Frequency            string     `json:"frequency" binding:"required,oneof=daily weekly monthly quarterly yearly"`
+	StartDate            time.Time  `json:"start_date" binding:"required"`
+	EndDate              *time.Time `json:"end_date"`
+	MaxRetries           *int       `json:"max_retries" binding:"omitnil,gte=0,lte=10"`
+	RetryIntervalHours   *int       `json:"retry_interval_hours" binding:"omitnil,gt=0,lte=168"`
+	PauseAfterFailures   *int       `json:"pause_after_failures" binding:"omitnil,gt=0,lte=30"`
+	CustomerIDs          []uint     `json:"customer_ids" binding:"required,min=1,dive,gt=0"`
+}
+
+func (r StandingInstructionRequest) instruction() models.StandingInstruction {
+	instruction := models.StandingInstruction{
+		SourceAccountID:      r.SourceAccountID,
+		DestinationAccountID: r.DestinationAccountID,
+		Amount:               r.Amount,
+		Description:          r.Description,
+		Frequency:            r.Frequency,
+		StartDate:            r.StartDate,
+		EndDate:              r.EndDate,
+		CustomerIDs:          r.CustomerIDs,
+		MaxRetries:           2,
+		RetryIntervalHours:   4,
+		PauseAfterFailures:   3,
+	}
+	if r.MaxRetries != nil {
+		instruction.MaxRetries = *r.MaxRetries
+	}
+	if r.RetryIntervalHours != nil {
+		instruction.RetryIntervalHours = *r.RetryIntervalHours
+	}
+	if r.PauseAfterFailures != nil {
+		instruction.PauseAfterFailures = *r.PauseAfterFailures
+	}
+	return instruction
+}
+
+type PauseInstructionRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+func (c *StandingInstructionController) CreateStandingInstruction(ctx *gin.Context) {
+	var req StandingInstructionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	instruction := req.instruction()
+	if err := c.service.Create(ctx.Request.Context(), &instruction); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, instruction.Version)
+	ctx.JSON(http.StatusCreated, instruction)
+}
+
+func (c *StandingInstructionController) GetAllStandingInstructions(ctx *gin.Context) {
+	accountID, ok := accountFilter(ctx)
+	if !ok {
+		return
+	}
+
+	instructions, err := c.service.GetAll(ctx.Request.Context(), accountID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, instructions)
+}
+
+func (c *StandingInstructionController) GetStandingInstructionByID(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid standing instruction id"))
+		return
+	}
+
+	instruction, err := c.service.GetByID(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, instruction.Version)
+	ctx.JSON(http.StatusOK, instruction)
+}
+
+func (c *StandingInstructionController) GetStandingInstructionExecutions(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid standing instruction id"))
+		return
+	}
+
+	executions, err := c.service.GetExecutions(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, executions)
+}
+
+func (c *StandingInstructionController) PauseStandingInstruction(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid standing instruction id"))
+		return
+	}
+
+	var req PauseInstructionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	instruction, err := c.service.Pause(ctx.Request.Context(), uint(id), req.Reason)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, instruction.Version)
+	ctx.JSON(http.StatusOK, instruction)
+}
+
+func (c *StandingInstructionController) ResumeStandingInstruction(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid standing instruction id"))
+		return
+	}
+
+	instruction, err := c.service.Resume(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, instruction.Version)
+	ctx.JSON(http.StatusOK, instruction)
+}
+
+func (c *StandingInstructionController) CancelStandingInstruction(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_id", "invalid standing instruction id"))
+		return
+	}
+
+	instruction, err := c.service.Cancel(ctx.Request.Context(), uint(id))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	setETag(ctx, instruction.Version)
+	ctx.JSON(http.StatusOK, instruction)
+}
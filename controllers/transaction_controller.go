@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"strconv"
 
-	"banking_system/models"
 	"banking_system/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,84 +17,29 @@ func NewTransactionController(service *services.TransactionService) *Transaction
 	return &TransactionController{service: service}
 }
 
-func (c *TransactionController) CreateTransaction(ctx *gin.Context) {
-	var txn models.Transaction
-	if err := ctx.ShouldBindJSON(&txn); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.service.Create(&txn); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusCreated, txn)
-}
-
 func (c *TransactionController) GetTransactionByID(ctx *gin.Context) {
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+		respondError(ctx, services.ValidationError("invalid_id", "invalid transaction id"))
 		return
 	}
 
-	txn, err := c.service.GetByID(uint(id))
+	txn, err := c.service.GetByID(ctx.Request.Context(), uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		respondError(ctx, err)
 		return
 	}
 
+	setETag(ctx, txn.Version)
 	ctx.JSON(http.StatusOK, txn)
 }
 
 func (c *TransactionController) GetAllTransactions(ctx *gin.Context) {
-	txs, err := c.service.GetAll()
+	txs, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, txs)
 }
 
-func (c *TransactionController) UpdateTransaction(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
-		return
-	}
-
-	txn, err := c.service.GetByID(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
-		return
-	}
-
-	if err := ctx.ShouldBindJSON(txn); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.service.Update(txn); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.JSON(http.StatusOK, txn)
-}
-
-func (c *TransactionController) DeleteTransaction(ctx *gin.Context) {
-	id, err := strconv.Atoi(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
-		return
-	}
-
-	if err := c.service.Delete(uint(id)); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx.Status(http.StatusNoContent)
-}
-
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"banking_system/identifiers"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[1-9][0-9]{6,14}$`)
+
+// RegisterValidators reports validation failures by JSON field name and adds the custom rules
+// used by the request types. It must run before the router serves requests.
+func RegisterValidators() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	_ = engine.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(fl.Field().String())
+	})
+	_ = engine.RegisterValidation("bank_code", func(fl validator.FieldLevel) bool {
+		return identifiers.ValidBIC(identifiers.Normalize(fl.Field().String()))
+	})
+	_ = engine.RegisterValidation("routing_code", func(fl validator.FieldLevel) bool {
+		return identifiers.ValidRoutingCode(identifiers.Normalize(fl.Field().String()))
+	})
+}
+
+// bindPatch binds a partial update. Only fields declared on req are accepted, anything else in
+// the body (balance, status, ids, timestamps...) is rejected rather than silently ignored.
+func bindPatch(ctx *gin.Context, req interface{}) bool {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&raw); err != nil {
+		respondError(ctx, services.ValidationError("invalid_body", err.Error()))
+		return false
+	}
+
+	allowed := jsonFields(req)
+	var rejected []services.FieldError
+	for field := range raw {
+		if !allowed[field] {
+			rejected = append(rejected, services.FieldError{Field: field, Message: "is not updatable"})
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Slice(rejected, func(i, j int) bool { return rejected[i].Field < rejected[j].Field })
+		err := services.FieldValidationError(rejected...)
+		err.Code = "field_not_updatable"
+		respondError(ctx, err)
+		return false
+	}
+
+	body, err := json.Marshal(raw)
+	if err != nil {
+		respondError(ctx, services.ValidationError("invalid_body", err.Error()))
+		return false
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		respondError(ctx, bindingError(err))
+		return false
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		respondError(ctx, bindingError(err))
+		return false
+	}
+	return true
+}
+
+func jsonFields(req interface{}) map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.SplitN(t.Field(i).Tag.Get("json"), ",", 2)[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// bindJSON binds and validates the request body, rendering a field-level problem on failure.
+func bindJSON(ctx *gin.Context, req interface{}) bool {
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		respondError(ctx, bindingError(err))
+		return false
+	}
+	return true
+}
+
+func bindingError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]services.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, services.FieldError{Field: fe.Field(), Message: validationMessage(fe)})
+		}
+		return services.FieldValidationError(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return services.FieldValidationError(services.FieldError{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type.String()),
+		})
+	}
+
+	return services.ValidationError("invalid_body", err.Error())
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "phone":
+		return "must be a valid phone number (digits only, optional leading +)"
+	case "bank_code":
+		return "must be a BIC/SWIFT code, e.g. HDFCINBBXXX"
+	case "routing_code":
+		return "must be a routing code: the 4 letter bank code, 0 and 6 letters or digits, e.g. HDFC0001234"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		//without a parameter lt compares dates against now
+		if fe.Param() == "" {
+			return "must be in the past"
+		}
+		return "must be less than " + fe.Param()
+	case "iso3166_1_alpha2":
+		return "must be a two letter ISO country code, e.g. IN"
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters"
+		}
+		return "must be at most " + fe.Param()
+	case "min":
+		if fe.Kind() == reflect.String && fe.Param() == "1" {
+			return "must not be empty"
+		}
+		if fe.Kind() == reflect.String {
+			return "must be at least " + fe.Param() + " characters"
+		}
+		return "must be at least " + fe.Param()
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
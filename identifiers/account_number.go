@@ -0,0 +1,42 @@
+package identifiers
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// AccountNumberSequenceWidth is the zero-padded width of the per-branch sequence.
+const AccountNumberSequenceWidth = 8
+
+// AccountNumber builds <branch prefix><sequence><check digits>, e.g. branch "MUM01" and sequence 42
+// give "MUM010000004240".
+func AccountNumber(branchCode string, sequence uint64) (string, error) {
+	prefix := branchPrefix(branchCode)
+	if prefix == "" {
+		return "", fmt.Errorf("identifiers: branch code %q has no usable characters", branchCode)
+	}
+	payload := fmt.Sprintf("%s%0*d", prefix, AccountNumberSequenceWidth, sequence)
+	digits, err := CheckDigits(payload)
+	if err != nil {
+		return "", err
+	}
+	return payload + digits, nil
+}
+
+// ValidAccountNumber checks the format and check digits of a normalized account number.
+func ValidAccountNumber(number string) bool {
+	if len(number) < AccountNumberSequenceWidth+3 {
+		return false
+	}
+	return ValidCheckDigits(number)
+}
+
+func branchPrefix(code string) string {
+	prefix := make([]rune, 0, len(code))
+	for _, r := range Normalize(code) {
+		if r < unicode.MaxASCII && (unicode.IsDigit(r) || unicode.IsUpper(r)) {
+			prefix = append(prefix, r)
+		}
+	}
+	return string(prefix)
+}
@@ -0,0 +1,41 @@
+package identifiers
+
+import "regexp"
+
+var (
+	bicPattern         = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	routingCodePattern = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+)
+
+// ValidBIC checks the ISO 9362 shape of a bank code: 4 letters institution, 2 letters country,
+// 2 characters location and an optional 3 character branch part.
+func ValidBIC(code string) bool {
+	return bicPattern.MatchString(code)
+}
+
+// InstitutionCode returns the 4 letter institution part of a BIC.
+func InstitutionCode(bic string) string {
+	if len(bic) < 4 {
+		return ""
+	}
+	return bic[:4]
+}
+
+// CountryCode returns the ISO 3166 country part of a BIC.
+func CountryCode(bic string) string {
+	if len(bic) < 6 {
+		return ""
+	}
+	return bic[4:6]
+}
+
+// ValidRoutingCode checks an IFSC-like branch routing code: the bank's institution code, a
+// literal 0 and 6 characters identifying the branch, e.g. HDFC0001234.
+func ValidRoutingCode(code string) bool {
+	return routingCodePattern.MatchString(code)
+}
+
+// RoutingCodeMatchesBank reports whether a routing code belongs to the bank with the given BIC.
+func RoutingCodeMatchesBank(routingCode, bic string) bool {
+	return len(routingCode) > 4 && routingCode[:4] == InstitutionCode(bic)
+}
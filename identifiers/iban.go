@@ -0,0 +1,37 @@
+package identifiers
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+
+// IBAN builds an IBAN from a country code and a domestic account identifier (BBAN).
+func IBAN(country, bban string) (string, error) {
+	if len(country) != 2 {
+		return "", fmt.Errorf("identifiers: invalid country code %q", country)
+	}
+	digits, err := CheckDigits(bban + country)
+	if err != nil {
+		return "", err
+	}
+	iban := country + digits + bban
+	if !ibanPattern.MatchString(iban) {
+		return "", fmt.Errorf("identifiers: %q does not fit the IBAN format", iban)
+	}
+	return iban, nil
+}
+
+// ValidIBAN checks the format and the mod-97 check digits of a normalized IBAN.
+func ValidIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	return ValidCheckDigits(iban[4:] + iban[:4])
+}
+
+// SplitIBAN returns the country code and BBAN of a valid IBAN.
+func SplitIBAN(iban string) (country, bban string) {
+	return iban[:2], iban[4:]
+}
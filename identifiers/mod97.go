@@ -0,0 +1,51 @@
+package identifiers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mod97 computes the ISO 7064 MOD 97-10 remainder of an alphanumeric string, letters count as
+// two digits (A=10 ... Z=35) the same way IBANs are checked.
+func mod97(s string) (int, bool) {
+	remainder := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return 0, false
+		}
+	}
+	return remainder, true
+}
+
+// CheckDigits returns the two check digits that make payload+digits pass ValidCheckDigits.
+func CheckDigits(payload string) (string, error) {
+	remainder, ok := mod97(payload + "00")
+	if !ok {
+		return "", fmt.Errorf("identifiers: %q contains characters other than A-Z and 0-9", payload)
+	}
+	return fmt.Sprintf("%02d", 98-remainder), nil
+}
+
+// ValidCheckDigits reports whether the last two digits of s are the mod-97 check digits of the rest.
+func ValidCheckDigits(s string) bool {
+	if len(s) < 3 {
+		return false
+	}
+	remainder, ok := mod97(s)
+	return ok && remainder == 1
+}
+
+// Normalize uppercases an identifier and drops the spaces and dashes people type into them.
+func Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(s)))
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"banking_system/config"
+	"banking_system/services"
+)
+
+// Setup registers the background jobs on the services the API uses, the returned scheduler still
+// has to be started. It fails when a job's interval is misconfigured.
+func Setup(accountService *services.AccountService, customerService *services.CustomerService, depositService *services.DepositService, standingInstructionService *services.StandingInstructionService) (*Scheduler, error) {
+	scheduler := NewScheduler()
+
+	dormancyMonths := config.GetEnvInt("DORMANCY_MONTHS", 12)
+	if err := scheduler.Every("dormancy_sweep", config.GetEnvDuration("DORMANCY_SWEEP_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+		cutoff := time.Now().AddDate(0, -dormancyMonths, 0)
+		changed, err := accountService.MarkDormant(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if changed > 0 {
+			slog.Info("accounts marked dormant", "count", changed, "inactive_since", cutoff)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := scheduler.Every("kyc_expiry", config.GetEnvDuration("KYC_EXPIRY_SWEEP_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+		expired, err := customerService.ExpireKYC(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			slog.Info("customer kyc verifications expired", "count", expired)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	depositInterval := config.GetEnvDuration("DEPOSIT_JOB_INTERVAL", time.Hour)
+	if err := scheduler.Every("recurring_deposit_debits", depositInterval, func(ctx context.Context) error {
+		paid, missed, err := depositService.CollectInstallments(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if paid+missed > 0 {
+			slog.Info("recurring deposit installments collected", "paid", paid, "missed", missed)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := scheduler.Every("deposit_maturity", depositInterval, func(ctx context.Context) error {
+		matured, err := depositService.ProcessMaturities(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if matured > 0 {
+			slog.Info("deposits matured", "count", matured)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := scheduler.Every("standing_instructions", config.GetEnvDuration("STANDING_INSTRUCTION_INTERVAL", 15*time.Minute), func(ctx context.Context) error {
+		succeeded, failed, err := standingInstructionService.RunDue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if succeeded+failed > 0 {
+			slog.Info("standing instructions executed", "succeeded", succeeded, "failed", failed)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return scheduler, nil
+}
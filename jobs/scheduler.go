@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work run on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs on their own tickers until it is stopped.
+type Scheduler struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job, refusing an interval that isn't positive so a misconfigured job fails
+// at startup instead of panicking in its ticker.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive, got %s", name, interval)
+	}
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+	return nil
+}
+
+// Start launches every job in its own goroutine, each job runs once immediately and then on its interval.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels the running jobs and waits for the current runs to return.
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("job panicked", "job", job.Name, "panic", r)
+		}
+	}()
+
+	if err := job.Run(ctx); err != nil {
+		slog.Error("job failed", "job", job.Name, "error", err, "duration", time.Since(start))
+		return
+	}
+	slog.Debug("job completed", "job", job.Name, "duration", time.Since(start))
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger routes GORM's output through slog so statements carry the request id of the caller.
+type GormLogger struct {
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	level := logger.Warn
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		level = logger.Info
+	}
+	return &GormLogger{level: level, slowThreshold: slowThreshold}
+}
+
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	log := FromContext(ctx)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		log.Error("query failed", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		log.Warn("slow query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		log.Debug("query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
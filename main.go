@@ -1,74 +1,95 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"banking_system/config"
-	"banking_system/models"
+	"banking_system/jobs"
+	"banking_system/logging"
+	"banking_system/metrics"
 	"banking_system/routes"
+
+	"github.com/joho/godotenv"
 )
 
 func main() {
+	_ = godotenv.Load()
+	logging.Init()
+
 	config.InitDB()
+	defer config.CloseDB()
 
-	config.DB.Migrator().DropTable(
-		&models.Transaction{},
-		&models.Repayment{},
-		&models.Loan{},
-		&models.AccountCustomer{},
-		&models.Account{},
-		&models.Customer{},
-		&models.Branch{},
-		&models.Bank{},
-	)
-
-	err := config.DB.AutoMigrate(
-		&models.Bank{},
-		&models.Branch{},
-		&models.Customer{},
-		&models.Account{},
-		&models.AccountCustomer{},
-		&models.Loan{},
-		&models.Repayment{},
-		&models.Transaction{},
-	)
-	if err != nil {
-		log.Println("Migration failed, dropping and recreating tables...")
-		config.DB.Migrator().DropTable(
-			&models.Transaction{},
-			&models.Repayment{},
-			&models.Loan{},
-			&models.AccountCustomer{},
-			&models.Account{},
-			&models.Customer{},
-			&models.Branch{},
-			&models.Bank{},
-		)
-		err = config.DB.AutoMigrate(
-			&models.Bank{},
-			&models.Branch{},
-			&models.Customer{},
-			&models.Account{},
-			&models.AccountCustomer{},
-			&models.Loan{},
-			&models.Repayment{},
-			&models.Transaction{},
-		)
-		if err != nil {
-			log.Fatalf("failed to run migrations after dropping tables: %v", err)
-		}
+	if err := config.Migrate(config.DB); err != nil {
+		fatal("failed to run migrations", err)
 	}
 
-	router := routes.SetupRouter(config.DB)
+	if err := metrics.RegisterDB(config.DB); err != nil {
+		fatal("failed to register database metrics", err)
+	}
+
+	svc := routes.NewServices(config.DB)
+	router := routes.SetupRouter(svc)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("failed to start server: %v", err)
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           router,
+		ReadHeaderTimeout: config.GetEnvDuration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       config.GetEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:      config.GetEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       config.GetEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheduler, err := jobs.Setup(svc.Account, svc.Customer, svc.Deposit, svc.StandingInstruction)
+	if err != nil {
+		fatal("failed to schedule background jobs", err)
 	}
+	scheduler.Start(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			fatal("failed to start server", err)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown did not complete", "error", err)
+	}
+	scheduler.Stop()
 }
 
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	config.CloseDB()
+	os.Exit(1)
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startTimeKey = "metrics:start_time"
+
+// GormPlugin records the duration of every statement GORM executes
+type GormPlugin struct{}
+
+func (p *GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	type processor struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}
+
+	processors := []processor{
+		{"create", db.Callback().Create().Before("gorm:create").Register, db.Callback().Create().After("gorm:create").Register},
+		{"query", db.Callback().Query().Before("gorm:query").Register, db.Callback().Query().After("gorm:query").Register},
+		{"update", db.Callback().Update().Before("gorm:update").Register, db.Callback().Update().After("gorm:update").Register},
+		{"delete", db.Callback().Delete().Before("gorm:delete").Register, db.Callback().Delete().After("gorm:delete").Register},
+		{"row", db.Callback().Row().Before("gorm:row").Register, db.Callback().Row().After("gorm:row").Register},
+		{"raw", db.Callback().Raw().Before("gorm:raw").Register, db.Callback().Raw().After("gorm:raw").Register},
+	}
+
+	for _, proc := range processors {
+		operation := proc.operation
+		if err := proc.before("metrics:before_"+operation, before); err != nil {
+			return err
+		}
+		if err := proc.after("metrics:after_"+operation, func(tx *gorm.DB) { after(tx, operation) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(tx *gorm.DB) {
+	tx.InstanceSet(startTimeKey, time.Now())
+}
+
+func after(tx *gorm.DB, operation string) {
+	value, ok := tx.InstanceGet(startTimeKey)
+	if !ok {
+		return
+	}
+	start, ok := value.(time.Time)
+	if !ok {
+		return
+	}
+	table := tx.Statement.Table
+	if table == "" {
+		table = "unknown"
+	}
+	DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+}
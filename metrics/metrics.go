@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const namespace = "banking"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests processed, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "GORM statement latency, by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	Deposits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deposits_total",
+		Help:      "Completed deposits, by account type.",
+	}, []string{"account_type"})
+
+	DepositAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deposit_amount_total",
+		Help:      "Deposited volume, by account type.",
+	}, []string{"account_type"})
+
+	Withdrawals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_total",
+		Help:      "Completed withdrawals, by account type.",
+	}, []string{"account_type"})
+
+	WithdrawalAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawal_amount_total",
+		Help:      "Withdrawn volume, by account type.",
+	}, []string{"account_type"})
+
+	FailedWithdrawals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_withdrawals_total",
+		Help:      "Rejected withdrawals, by account type and reason.",
+	}, []string{"account_type", "reason"})
+
+	LoansCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_created_total",
+		Help:      "Loans created.",
+	})
+
+	LoansClosed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_closed_total",
+		Help:      "Loans closed after full repayment.",
+	})
+)
+
+// RegisterDB exposes connection pool stats and per-statement timings for the given connection
+func RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, namespace)); err != nil {
+		return err
+	}
+	return db.Use(&GormPlugin{})
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	UserIDHeader   = "X-User-ID"
+	UserRoleHeader = "X-User-Role"
+)
+
+// Actor puts the employee identified by the gateway headers into the request context, services
+// use it to record and check who makes and who approves sensitive operations.
+func Actor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if id := ctx.GetHeader(UserIDHeader); id != "" {
+			actor := services.Actor{ID: id, Role: ctx.GetHeader(UserRoleHeader)}
+			ctx.Request = ctx.Request.WithContext(services.WithActor(ctx.Request.Context(), actor))
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"banking_system/logging"
+	"banking_system/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is the RFC 7807 body returned for every error. Code is stable and meant for
+// programmatic handling, Type is derived from it.
+type Problem struct {
+	Type      string                `json:"type"`
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail,omitempty"`
+	Instance  string                `json:"instance,omitempty"`
+	Code      string                `json:"code"`
+	Errors    []services.FieldError `json:"errors,omitempty"`
+	Limit     string                `json:"limit,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+}
+
+var problemStatus = map[services.ErrorKind]int{
+	services.KindNotFound:             http.StatusNotFound,
+	services.KindConflict:             http.StatusConflict,
+	services.KindInsufficientFunds:    http.StatusUnprocessableEntity,
+	services.KindValidation:           http.StatusBadRequest,
+	services.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	services.KindPreconditionRequired: http.StatusPreconditionRequired,
+	services.KindForbidden:            http.StatusForbidden,
+	services.KindLimitExceeded:        http.StatusUnprocessableEntity,
+}
+
+// ErrorHandler renders the last error a handler attached with ctx.Error, unless the handler
+// already wrote a response itself.
+func ErrorHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+		WriteProblem(ctx, ctx.Errors.Last().Err)
+	}
+}
+
+func WriteProblem(ctx *gin.Context, err error) {
+	problem := newProblem(err)
+	problem.Instance = ctx.Request.URL.Path
+	problem.RequestID = logging.RequestID(ctx.Request.Context())
+
+	if problem.Status >= http.StatusInternalServerError {
+		logging.FromContext(ctx.Request.Context()).Error("request failed", "error", err)
+	}
+
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.AbortWithStatusJSON(problem.Status, problem)
+}
+
+func newProblem(err error) Problem {
+	var domainErr *services.Error
+	if errors.As(err, &domainErr) {
+		status, ok := problemStatus[domainErr.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		return Problem{
+			Type:   "/problems/" + domainErr.Code,
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: domainErr.Message,
+			Code:   domainErr.Code,
+			Errors: domainErr.Fields,
+			Limit:  domainErr.Limit,
+		}
+	}
+
+	// raw database or driver errors are not meant for clients
+	return Problem{
+		Type:   "/problems/internal_error",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Detail: "an unexpected error occurred",
+		Code:   "internal_error",
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"banking_system/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog writes one structured line per request in place of gin's plain-text logger.
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []any{
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"route", ctx.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", ctx.ClientIP(),
+			"bytes", ctx.Writer.Size(),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, "errors", ctx.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logging.FromContext(ctx.Request.Context()).Log(ctx.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+// Recovery logs panics with their stack trace and answers with an internal_error problem.
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logging.FromContext(ctx.Request.Context()).Error("panic recovered",
+					"panic", recovered,
+					"stack", string(debug.Stack()),
+				)
+				WriteProblem(ctx, fmt.Errorf("panic: %v", recovered))
+			}
+		}()
+		ctx.Next()
+	}
+}
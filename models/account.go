@@ -1,26 +1,56 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccountStatusOpen    = "open"
+	AccountStatusFrozen  = "frozen"
+	AccountStatusDormant = "dormant"
+	AccountStatusClosed  = "closed"
+)
+
+// operating mandates decide whose instructions the bank accepts on a joint account
+const (
+	MandateEitherOrSurvivor = "either_or_survivor"
+	MandateFormerOrSurvivor = "former_or_survivor"
+	MandateJointly          = "jointly"
+)
 
 type Account struct {
-	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	AccountNumber string    `gorm:"size:30;not null;uniqueIndex" json:"account_number"`
-	BranchID      uint      `gorm:"not null;index" json:"branch_id"`
-	Branch        Branch    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
-	AccountType   string    `gorm:"size:20;not null;default:savings" json:"account_type"`
-	Interest      float64   `gorm:"not null;default:0" json:"interest"`
-	Balance       float64   `gorm:"not null;default:0" json:"balance"`
-	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID               uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountNumber    string         `gorm:"size:30;not null;uniqueIndex" json:"account_number"`
+	BranchID         uint           `gorm:"not null;index" json:"branch_id"`
+	Branch           Branch         `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	AccountType      string         `gorm:"size:20;not null;default:savings" json:"account_type"`
+	Joint            bool           `gorm:"not null;default:false" json:"joint"`
+	Interest         float64        `gorm:"not null;default:0" json:"interest"`
+	Balance          float64        `gorm:"not null;default:0" json:"balance"`
+	OperatingMandate string         `gorm:"size:30;not null;default:either_or_survivor" json:"operating_mandate"`
+	Status           string         `gorm:"size:20;not null;default:open;index" json:"status"`
+	StatusReason     string         `gorm:"size:255" json:"status_reason"`
+	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	Version          uint           `gorm:"not null;default:1" json:"version"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 type AccountDetail struct {
 	ID            uint `json:"account_id"`
 	AccountNumber string `json:"account_number"`
+	IBAN          string `json:"iban"`
 	BranchID      uint `json:"branch_id"`
 	AccountType   string `json:"account_type"`
+	Joint         bool `json:"joint"`
 	Interest      float64 `json:"interest"`
 	Balance       float64 `json:"balance"`
+	OperatingMandate string `json:"operating_mandate"`
+	Status        string `json:"status"`
+	StatusReason  string `json:"status_reason"`
 	CreatedAt     time.Time `json:"created_at"`
+	Version       uint `json:"version"`
 	Customers     []CustomerInfo `json:"customers"`
 }
 
@@ -2,6 +2,14 @@ package models
 
 import "time"
 
+const (
+	HolderRolePrimary             = "primary_holder"
+	HolderRoleJoint               = "joint_holder"
+	HolderRoleNominee             = "nominee"
+	HolderRoleAuthorizedSignatory = "authorized_signatory"
+	HolderRoleGuardian            = "guardian"
+)
+
 //AccountCustomer also handles the case for joint accounts where we specify the type of account (Current, Savings and Joint)
 //basically the mapping b/w account and customer table
 type AccountCustomer struct {
@@ -0,0 +1,8 @@
+package models
+
+// AccountSequence hands out account number sequences per branch
+type AccountSequence struct {
+	BranchID  uint   `gorm:"primaryKey;autoIncrement:false" json:"branch_id"`
+	Branch    Branch `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	LastValue uint64 `gorm:"not null;default:0" json:"last_value"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// AccountStatusChange keeps the history of every lifecycle transition of an account
+type AccountStatusChange struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID  uint      `gorm:"not null;index" json:"account_id"`
+	Account    Account   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	FromStatus string    `gorm:"size:20;not null" json:"from_status"`
+	ToStatus   string    `gorm:"size:20;not null" json:"to_status"`
+	Reason     string    `gorm:"size:255" json:"reason"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"changed_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ApprovalEvent is one entry in the history of an approval request
+type ApprovalEvent struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ApprovalRequestID uint      `gorm:"not null;index" json:"approval_request_id"`
+	Action            string    `gorm:"size:20;not null" json:"action"`
+	ActorID           string    `gorm:"size:100" json:"actor_id"`
+	ActorRole         string    `gorm:"size:50" json:"actor_role"`
+	Note              string    `gorm:"size:255" json:"note,omitempty"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusExecuted = "executed"
+	ApprovalStatusFailed   = "failed"
+	ApprovalStatusRejected = "rejected"
+)
+
+// ApprovalRequest is a sensitive operation parked until a second person (the checker) approves it.
+// Payload holds the arguments the operation is executed with once approved.
+type ApprovalRequest struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Operation     string          `gorm:"size:50;not null;index" json:"operation"`
+	Status        string          `gorm:"size:20;not null;default:pending;index" json:"status"`
+	ResourceType  string          `gorm:"size:50;not null" json:"resource_type"`
+	ResourceID    uint            `gorm:"not null;index" json:"resource_id"`
+	Amount        float64         `gorm:"not null;default:0" json:"amount"`
+	Payload       json.RawMessage `gorm:"type:jsonb;not null" json:"payload"`
+	MakerID       string          `gorm:"size:100;not null" json:"maker_id"`
+	MakerRole     string          `gorm:"size:50" json:"maker_role"`
+	CheckerID     string          `gorm:"size:100" json:"checker_id,omitempty"`
+	DecisionNote  string          `gorm:"size:255" json:"decision_note,omitempty"`
+	Result        json.RawMessage `gorm:"type:jsonb" json:"result,omitempty"`
+	FailureReason string          `gorm:"size:255" json:"failure_reason,omitempty"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	DecidedAt     *time.Time      `json:"decided_at,omitempty"`
+	Version       uint            `gorm:"not null;default:1" json:"version"`
+	Events        []ApprovalEvent `gorm:"foreignKey:ApprovalRequestID" json:"events,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// BenchmarkRate is an external reference rate (e.g. a central bank repo rate) floating loans are
+// priced against
+type BenchmarkRate struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code          string    `gorm:"size:20;not null;unique" json:"code"`
+	Name          string    `gorm:"size:100;not null" json:"name"`
+	Rate          float64   `gorm:"not null" json:"rate"`
+	EffectiveFrom time.Time `gorm:"not null" json:"effective_from"`
+	Version       uint      `gorm:"not null;default:1" json:"version"`
+}
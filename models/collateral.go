@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+const (
+	CollateralProperty   = "property"
+	CollateralVehicle    = "vehicle"
+	CollateralDeposit    = "deposit"
+	CollateralSecurities = "securities"
+	CollateralGold       = "gold"
+)
+
+const (
+	LienPending  = "pending"
+	LienMarked   = "marked"
+	LienReleased = "released"
+)
+
+// Collateral is an asset pledged against a loan. It is pledged on the application, so the
+// loan-to-value can be checked at approval, and the lien is marked once the loan is booked.
+type Collateral struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	ApplicationID uint            `gorm:"not null;index" json:"application_id"`
+	Application   LoanApplication `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	LoanID        *uint           `gorm:"index" json:"loan_id,omitempty"`
+	Loan          *Loan           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Type          string          `gorm:"size:20;not null" json:"collateral_type"`
+	Description   string          `gorm:"size:255" json:"description"`
+	Valuation     float64         `gorm:"not null" json:"valuation"`
+	ValuationDate time.Time       `gorm:"not null" json:"valuation_date"`
+	LienStatus    string          `gorm:"size:10;not null;default:pending" json:"lien_status"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	Version       uint            `gorm:"not null;default:1" json:"version"`
+}
@@ -1,10 +1,32 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	KYCPending  = "pending"
+	KYCVerified = "verified"
+	KYCRejected = "rejected"
+	// expired verifications have to be redone before the customer can be linked to accounts again
+	KYCExpired = "expired"
+)
+
 type Customer struct {
-	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
-	FirstName string `gorm:"size:100" json:"first_name"`
-	LastName  string `gorm:"size:100" json:"last_name"`
-	Email     string `gorm:"size:150;uniqueIndex" json:"email"`
-	Phone     string `gorm:"size:20;uniqueIndex" json:"phone_number"`
+	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	FirstName     string         `gorm:"size:100" json:"first_name"`
+	LastName      string         `gorm:"size:100" json:"last_name"`
+	Email         string         `gorm:"size:150;uniqueIndex" json:"email"`
+	Phone         string         `gorm:"size:20;uniqueIndex" json:"phone_number"`
+	DateOfBirth   *time.Time     `gorm:"type:date" json:"date_of_birth,omitempty"`
+	KYCStatus     string         `gorm:"column:kyc_status;size:10;not null;default:pending;index" json:"kyc_status"`
+	KYCVerifiedAt *time.Time     `gorm:"column:kyc_verified_at" json:"kyc_verified_at,omitempty"`
+	KYCReviewedBy string         `gorm:"column:kyc_reviewed_by;size:100" json:"kyc_reviewed_by,omitempty"`
+	KYCNote       string         `gorm:"column:kyc_note;size:255" json:"kyc_note,omitempty"`
+	KYCExpiresAt  *time.Time     `gorm:"column:kyc_expires_at;index" json:"kyc_expires_at,omitempty"`
+	Version       uint           `gorm:"not null;default:1" json:"version"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
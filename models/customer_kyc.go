@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	AddressResidential = "residential"
+	AddressPermanent   = "permanent"
+	AddressMailing     = "mailing"
+)
+
+const (
+	IDPassport       = "passport"
+	IDNationalID     = "national_id"
+	IDDrivingLicense = "driving_license"
+	IDTaxID          = "tax_id"
+)
+
+const (
+	DocumentIDProof      = "id_proof"
+	DocumentAddressProof = "address_proof"
+	DocumentPhoto        = "photo"
+	DocumentOther        = "other"
+)
+
+type CustomerAddress struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CustomerID uint      `gorm:"not null;index" json:"customer_id"`
+	Customer   Customer  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Type       string    `gorm:"size:15;not null" json:"address_type"`
+	Line1      string    `gorm:"size:255;not null" json:"line1"`
+	Line2      string    `gorm:"size:255" json:"line2,omitempty"`
+	City       string    `gorm:"size:100;not null" json:"city"`
+	State      string    `gorm:"size:100" json:"state,omitempty"`
+	PostalCode string    `gorm:"size:20;not null" json:"postal_code"`
+	Country    string    `gorm:"size:2;not null" json:"country"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// CustomerIdentity is a government issued identity number. A number is unique per type and
+// issuing country across all customers.
+type CustomerIdentity struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CustomerID     uint       `gorm:"not null;index" json:"customer_id"`
+	Customer       Customer   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Type           string     `gorm:"size:20;not null;uniqueIndex:idx_identity_number" json:"id_type"`
+	Number         string     `gorm:"size:50;not null;uniqueIndex:idx_identity_number" json:"id_number"`
+	IssuingCountry string     `gorm:"size:2;not null;uniqueIndex:idx_identity_number" json:"issuing_country"`
+	IssuedAt       *time.Time `gorm:"type:date" json:"issued_at,omitempty"`
+	ExpiresAt      *time.Time `gorm:"type:date" json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// CustomerDocument describes an uploaded file, the content itself lives in the blob store under
+// StorageKey.
+type CustomerDocument struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CustomerID  uint      `gorm:"not null;index" json:"customer_id"`
+	Customer    Customer  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Type        string    `gorm:"size:20;not null" json:"document_type"`
+	IdentityID  *uint     `gorm:"index" json:"identity_id,omitempty"`
+	FileName    string    `gorm:"size:255;not null" json:"file_name"`
+	ContentType string    `gorm:"size:100;not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	SHA256      string    `gorm:"column:sha256;size:64;not null" json:"sha256"`
+	StorageKey  string    `gorm:"size:255;not null;uniqueIndex" json:"-"`
+	UploadedBy  string    `gorm:"size:100" json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"uploaded_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// CustomerMerge records a duplicate customer folded into the survivor, with how many rows were
+// moved over. The duplicate is archived, so a customer can only be merged away once.
+type CustomerMerge struct {
+	ID                   uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SurvivorID           uint      `gorm:"not null;index" json:"survivor_id"`
+	Survivor             Customer  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	DuplicateID          uint      `gorm:"not null;uniqueIndex" json:"duplicate_id"`
+	Duplicate            Customer  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	AccountLinks         int64     `gorm:"not null;default:0" json:"account_links"`
+	Loans                int64     `gorm:"not null;default:0" json:"loans"`
+	LoanApplications     int64     `gorm:"not null;default:0" json:"loan_applications"`
+	Guarantees           int64     `gorm:"not null;default:0" json:"guarantees"`
+	StandingInstructions int64     `gorm:"not null;default:0" json:"standing_instructions"`
+	Reason               string    `gorm:"size:255" json:"reason,omitempty"`
+	MergedBy             string    `gorm:"size:100" json:"merged_by"`
+	CreatedAt            time.Time `gorm:"autoCreateTime" json:"merged_at"`
+}
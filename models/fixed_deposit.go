@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	CompoundMonthly    = "monthly"
+	CompoundQuarterly  = "quarterly"
+	CompoundHalfYearly = "half_yearly"
+	CompoundYearly     = "yearly"
+)
+
+const (
+	MaturityPayout    = "payout"
+	MaturityAutoRenew = "auto_renew"
+)
+
+const (
+	DepositActive  = "active"
+	DepositMatured = "matured"
+	// renewed deposits matured into a new deposit instead of being paid out
+	DepositRenewed = "renewed"
+)
+
+// FixedDeposit is a lump sum taken from an account and locked in for TenureMonths. On maturity
+// it is paid back into the account or renewed for the same tenure, as instructed.
+type FixedDeposit struct {
+	ID                  uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID           uint       `gorm:"not null;index" json:"account_id"`
+	Account             Account    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Principal           float64    `gorm:"not null" json:"principal"`
+	InterestRate        float64    `gorm:"not null" json:"interest_rate"`
+	TenureMonths        int        `gorm:"not null" json:"tenure_months"`
+	Compounding         string     `gorm:"size:15;not null;default:quarterly" json:"compounding"`
+	MaturityInstruction string     `gorm:"size:10;not null;default:payout" json:"maturity_instruction"`
+	StartDate           time.Time  `gorm:"not null" json:"start_date"`
+	MaturityDate        time.Time  `gorm:"not null;index" json:"maturity_date"`
+	MaturityAmount      float64    `gorm:"not null" json:"maturity_amount"`
+	Status              string     `gorm:"size:10;not null;default:active;index" json:"status"`
+	RenewedFromID       *uint      `gorm:"index" json:"renewed_from_id,omitempty"`
+	MaturedAt           *time.Time `json:"matured_at,omitempty"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Version             uint       `gorm:"not null;default:1" json:"version"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+const (
+	AlertOpen          = "open"
+	AlertInvestigating = "investigating"
+	// confirmed alerts were found to be fraud or money laundering, dismissed ones false positives
+	AlertConfirmed = "confirmed"
+	AlertDismissed = "dismissed"
+)
+
+// FraudAlert is raised by a monitoring rule on a transaction and worked as a case by an
+// investigator. AccountFrozen records that the alert froze the account.
+type FraudAlert struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID      uint       `gorm:"not null;index" json:"account_id"`
+	Account        Account    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	TransactionID  *uint      `gorm:"index" json:"transaction_id,omitempty"`
+	Rule           string     `gorm:"size:40;not null;index" json:"rule"`
+	Severity       string     `gorm:"size:10;not null;index" json:"severity"`
+	Description    string     `gorm:"size:255;not null" json:"description"`
+	Status         string     `gorm:"size:20;not null;default:open;index" json:"status"`
+	AccountFrozen  bool       `gorm:"not null;default:false" json:"account_frozen"`
+	AssignedTo     string     `gorm:"size:100" json:"assigned_to,omitempty"`
+	ResolutionNote string     `gorm:"size:255" json:"resolution_note,omitempty"`
+	ResolvedBy     string     `gorm:"size:100" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Version        uint       `gorm:"not null;default:1" json:"version"`
+}
@@ -1,17 +1,32 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Loan struct {
-	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	AccountID    uint      `gorm:"not null;index" json:"account_id"`
-	Account      Account   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
-	CustomerID   uint      `gorm:"not null;index" json:"customer_id"`
-	Customer     Customer  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
-	Amount       float64   `gorm:"column:loan_amount;not null" json:"loan_amount"`
-	InterestRate float64   `gorm:"column:loan_interest;not null" json:"loan_interest"` 
-	StartDate    time.Time `gorm:"not null" json:"start_date"`
-	TermMonths   int       `gorm:"not null" json:"term_months"`
-	Status       string    `gorm:"size:20;not null" json:"status"`
+	ID             uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID      uint           `gorm:"not null;index" json:"account_id"`
+	Account        Account        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	CustomerID     uint           `gorm:"not null;index" json:"customer_id"`
+	Customer       Customer       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	ProductID      uint           `gorm:"not null;index" json:"product_id"`
+	Product        LoanProduct    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	RateType       string         `gorm:"size:10;not null;default:fixed" json:"rate_type"`
+	BenchmarkID    *uint          `gorm:"index" json:"benchmark_id,omitempty"`
+	Spread         float64        `gorm:"not null;default:0" json:"spread"`
+	Amount         float64        `gorm:"column:loan_amount;not null" json:"loan_amount"`
+	InterestRate   float64        `gorm:"column:loan_interest;not null" json:"loan_interest"`
+	ProcessingFee  float64        `gorm:"not null;default:0" json:"processing_fee"`
+	Advance        float64        `gorm:"not null;default:0" json:"advance"`
+	TermsRevision  int            `gorm:"not null;default:1" json:"terms_revision"`
+	RestructuredAt *time.Time     `json:"restructured_at,omitempty"`
+	StartDate      time.Time      `gorm:"not null" json:"start_date"`
+	TermMonths     int            `gorm:"not null" json:"term_months"`
+	Status         string         `gorm:"size:20;not null" json:"status"`
+	ApplicationID  *uint          `gorm:"uniqueIndex" json:"application_id,omitempty"`
+	Version        uint           `gorm:"not null;default:1" json:"version"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
-
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	LoanApplicationSubmitted        = "submitted"
+	LoanApplicationAwaitingApproval = "awaiting_approval"
+	LoanApplicationApproved         = "approved"
+	LoanApplicationRejected         = "rejected"
+)
+
+// LoanApplication is a customer's request for a loan together with the eligibility snapshot the
+// officer decided on. Only approved applications turn into a Loan.
+type LoanApplication struct {
+	ID                 uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	CustomerID         uint        `gorm:"not null;index" json:"customer_id"`
+	Customer           Customer    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	AccountID          uint        `gorm:"not null;index" json:"account_id"`
+	Account            Account     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	ProductID          uint        `gorm:"not null;index" json:"product_id"`
+	Product            LoanProduct `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	RequestedAmount    float64     `gorm:"not null" json:"requested_amount"`
+	TermMonths         int         `gorm:"not null" json:"term_months"`
+	InterestRate       float64     `gorm:"not null" json:"interest_rate"`
+	Purpose            string      `gorm:"size:255" json:"purpose"`
+	MonthlyIncome      float64     `gorm:"not null" json:"monthly_income"`
+	Status             string      `gorm:"size:20;not null;default:submitted;index" json:"status"`
+	ExistingExposure   float64     `gorm:"not null;default:0" json:"existing_exposure"`
+	AverageBalance     float64     `gorm:"not null;default:0" json:"average_balance"`
+	MonthlyObligations float64     `gorm:"not null;default:0" json:"monthly_obligations"`
+	ProposedEMI        float64     `gorm:"column:proposed_emi;not null;default:0" json:"proposed_emi"`
+	DebtToIncome       float64     `gorm:"not null;default:0" json:"debt_to_income"`
+	Eligible           bool        `gorm:"not null;default:false" json:"eligible"`
+	EligibilityNotes   string      `gorm:"size:500" json:"eligibility_notes"`
+	EvaluatedAt        time.Time   `json:"evaluated_at"`
+	LoanToValue        *float64    `json:"loan_to_value,omitempty"`
+	OfficerID          string      `gorm:"size:100" json:"officer_id,omitempty"`
+	DecisionNote       string      `gorm:"size:255" json:"decision_note,omitempty"`
+	DecidedAt          *time.Time  `json:"decided_at,omitempty"`
+	LoanID             *uint       `gorm:"index" json:"loan_id,omitempty"`
+	CreatedAt          time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	Version            uint        `gorm:"not null;default:1" json:"version"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	GuaranteePending  = "pending"
+	GuaranteeActive   = "active"
+	GuaranteeReleased = "released"
+)
+
+// LoanGuarantor is a customer standing in for up to GuaranteeAmount of someone else's loan. Like
+// collateral it is offered on the application and becomes active when the loan is booked.
+type LoanGuarantor struct {
+	ID              uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	ApplicationID   uint            `gorm:"not null;uniqueIndex:idx_guarantor_application" json:"application_id"`
+	Application     LoanApplication `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	LoanID          *uint           `gorm:"index" json:"loan_id,omitempty"`
+	Loan            *Loan           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"loan,omitempty"`
+	CustomerID      uint            `gorm:"not null;uniqueIndex:idx_guarantor_application;index" json:"customer_id"`
+	Customer        Customer        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	GuaranteeAmount float64         `gorm:"not null" json:"guarantee_amount"`
+	Status          string          `gorm:"size:10;not null;default:pending" json:"status"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	ReleasedAt      *time.Time      `json:"released_at,omitempty"`
+	Version         uint            `gorm:"not null;default:1" json:"version"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	InstallmentPending = "pending"
+	InstallmentPaid    = "paid"
+	// settled installments were closed early by a foreclosure rather than paid on schedule
+	InstallmentSettled = "settled"
+	// restructured installments were replaced by a new schedule, what they still owed was rolled into it
+	InstallmentRestructured = "restructured"
+)
+
+// LoanInstallment is one line of a loan's amortization schedule. Unpaid lines are regenerated
+// when the loan is prepaid or repriced, paid lines are never touched again.
+type LoanInstallment struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	LoanID           uint       `gorm:"not null;uniqueIndex:idx_installment_number" json:"loan_id"`
+	Loan             Loan       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Number           int        `gorm:"not null;uniqueIndex:idx_installment_number" json:"number"`
+	DueDate          time.Time  `gorm:"not null;index" json:"due_date"`
+	OpeningPrincipal float64    `gorm:"not null" json:"opening_principal"`
+	Principal        float64    `gorm:"not null" json:"principal"`
+	Interest         float64    `gorm:"not null" json:"interest"`
+	Amount           float64    `gorm:"not null" json:"amount"`
+	PrincipalPaid    float64    `gorm:"not null;default:0" json:"principal_paid"`
+	InterestPaid     float64    `gorm:"not null;default:0" json:"interest_paid"`
+	Penalty          float64    `gorm:"not null;default:0" json:"penalty"`
+	PenaltyPaid      float64    `gorm:"not null;default:0" json:"penalty_paid"`
+	Status           string     `gorm:"size:10;not null;default:pending" json:"status"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	RateTypeFixed    = "fixed"
+	RateTypeFloating = "floating"
+)
+
+// LoanProduct is a catalog entry loans are sold under. Floating products are priced at their
+// benchmark plus Spread, fixed ones at FixedRate. Products with a MaxLTVPercent are secured and
+// need collateral.
+type LoanProduct struct {
+	ID                       uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code                     string         `gorm:"size:20;not null;unique" json:"code"`
+	Name                     string         `gorm:"size:100;not null" json:"name"`
+	MinAmount                float64        `gorm:"not null" json:"min_amount"`
+	MaxAmount                float64        `gorm:"not null" json:"max_amount"`
+	MinTermMonths            int            `gorm:"not null" json:"min_term_months"`
+	MaxTermMonths            int            `gorm:"not null" json:"max_term_months"`
+	RateType                 string         `gorm:"size:10;not null" json:"rate_type"`
+	FixedRate                float64        `gorm:"not null;default:0" json:"fixed_rate"`
+	BenchmarkID              *uint          `gorm:"index" json:"benchmark_id,omitempty"`
+	Benchmark                *BenchmarkRate `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Spread                   float64        `gorm:"not null;default:0" json:"spread"`
+	ProcessingFeePercent     float64        `gorm:"not null;default:0" json:"processing_fee_percent"`
+	MinProcessingFee         float64        `gorm:"not null;default:0" json:"min_processing_fee"`
+	PrepaymentAllowed        bool           `gorm:"not null;default:true" json:"prepayment_allowed"`
+	PrepaymentLockInMonths   int            `gorm:"not null;default:0" json:"prepayment_lock_in_months"`
+	PrepaymentPenaltyPercent float64        `gorm:"not null;default:0" json:"prepayment_penalty_percent"`
+	LatePaymentFeePercent    float64        `gorm:"not null;default:0" json:"late_payment_fee_percent"`
+	MaxLTVPercent            float64        `gorm:"column:max_ltv_percent;not null;default:0" json:"max_ltv_percent"`
+	Active                   bool           `gorm:"not null;default:true" json:"active"`
+	CreatedAt                time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	Version                  uint           `gorm:"not null;default:1" json:"version"`
+}
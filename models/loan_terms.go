@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// LoanTerms is one revision of a loan's repayment terms. Revision 1 is what the loan was booked
+// with, every restructuring adds the next one so prior terms stay queryable.
+type LoanTerms struct {
+	ID                  uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	LoanID              uint      `gorm:"not null;uniqueIndex:idx_loan_terms_revision" json:"loan_id"`
+	Loan                Loan      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Revision            int       `gorm:"not null;uniqueIndex:idx_loan_terms_revision" json:"revision"`
+	Principal           float64   `gorm:"not null" json:"principal"`
+	InterestRate        float64   `gorm:"not null" json:"interest_rate"`
+	TermMonths          int       `gorm:"not null" json:"term_months"`
+	MoratoriumMonths    int       `gorm:"not null;default:0" json:"moratorium_months"`
+	CapitalizedInterest float64   `gorm:"not null;default:0" json:"capitalized_interest"`
+	Installment         float64   `gorm:"not null" json:"installment"`
+	FirstDueDate        time.Time `gorm:"not null" json:"first_due_date"`
+	Reason              string    `gorm:"size:255" json:"reason"`
+	CreatedBy           string    `gorm:"size:64" json:"created_by,omitempty"`
+	EffectiveFrom       time.Time `gorm:"not null" json:"effective_from"`
+}
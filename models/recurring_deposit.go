@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+const (
+	RecurringInstallmentPaid   = "paid"
+	RecurringInstallmentMissed = "missed"
+)
+
+// RecurringDeposit collects InstallmentAmount from an account every month for TenureMonths and
+// pays out what was collected, with interest, on maturity. Missed installments are not made up,
+// they just earn nothing.
+type RecurringDeposit struct {
+	ID                     uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID              uint       `gorm:"not null;index" json:"account_id"`
+	Account                Account    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	InstallmentAmount      float64    `gorm:"not null" json:"installment_amount"`
+	InterestRate           float64    `gorm:"not null" json:"interest_rate"`
+	TenureMonths           int        `gorm:"not null" json:"tenure_months"`
+	Compounding            string     `gorm:"size:15;not null;default:quarterly" json:"compounding"`
+	StartDate              time.Time  `gorm:"not null" json:"start_date"`
+	NextDueDate            *time.Time `gorm:"index" json:"next_due_date,omitempty"`
+	MaturityDate           time.Time  `gorm:"not null;index" json:"maturity_date"`
+	InstallmentsPaid       int        `gorm:"not null;default:0" json:"installments_paid"`
+	InstallmentsMissed     int        `gorm:"not null;default:0" json:"installments_missed"`
+	ExpectedMaturityAmount float64    `gorm:"not null" json:"expected_maturity_amount"`
+	MaturityAmount         float64    `gorm:"not null;default:0" json:"maturity_amount"`
+	Status                 string     `gorm:"size:10;not null;default:active;index" json:"status"`
+	MaturedAt              *time.Time `json:"matured_at,omitempty"`
+	CreatedAt              time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Version                uint       `gorm:"not null;default:1" json:"version"`
+}
+
+type RecurringDepositInstallment struct {
+	ID            uint             `gorm:"primaryKey;autoIncrement" json:"id"`
+	DepositID     uint             `gorm:"not null;uniqueIndex:idx_rd_installment_number" json:"deposit_id"`
+	Deposit       RecurringDeposit `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Number        int              `gorm:"not null;uniqueIndex:idx_rd_installment_number" json:"number"`
+	DueDate       time.Time        `gorm:"not null" json:"due_date"`
+	Amount        float64          `gorm:"not null" json:"amount"`
+	Status        string           `gorm:"size:10;not null" json:"status"`
+	FailureReason string           `gorm:"size:255" json:"failure_reason,omitempty"`
+	TransactionID *uint            `json:"transaction_id,omitempty"`
+	ProcessedAt   time.Time        `gorm:"not null" json:"processed_at"`
+}
@@ -2,11 +2,21 @@ package models
 
 import "time"
 
+const (
+	RepaymentInstallment = "installment"
+	RepaymentPrepayment  = "prepayment"
+	RepaymentForeclosure = "foreclosure"
+)
+
 type Repayment struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	LoanID      uint      `gorm:"not null;index" json:"loan_id"`
-	Loan        Loan      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	Amount      float64   `gorm:"not null" json:"amount"`
-	PaymentDate time.Time `gorm:"column:repayment_date;not null" json:"repayment_date"`
+	ID             uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+	LoanID         uint                  `gorm:"not null;index" json:"loan_id"`
+	Loan           Loan                  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Type           string                `gorm:"size:20;not null;default:installment" json:"repayment_type"`
+	Amount         float64               `gorm:"not null" json:"amount"`
+	AdvanceApplied float64               `gorm:"not null;default:0" json:"advance_applied"`
+	PaymentDate    time.Time             `gorm:"column:repayment_date;not null" json:"repayment_date"`
+	Version        uint                  `gorm:"not null;default:1" json:"version"`
+	Allocations    []RepaymentAllocation `json:"allocations,omitempty"`
 }
 
@@ -0,0 +1,23 @@
+package models
+
+// components a repayment is split into, in the order they are settled
+const (
+	AllocationPenalty   = "penalty"
+	AllocationFee       = "fee"
+	AllocationInterest  = "interest"
+	AllocationPrincipal = "principal"
+	// what is left after the schedule is covered is either paid back or kept on the loan
+	AllocationRefund  = "refund"
+	AllocationAdvance = "advance"
+)
+
+// RepaymentAllocation records which part of a repayment went to which component, and for
+// scheduled components which installment it settled.
+type RepaymentAllocation struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RepaymentID   uint      `gorm:"not null;index" json:"repayment_id"`
+	Repayment     Repayment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	InstallmentID *uint     `gorm:"index" json:"installment_id,omitempty"`
+	Component     string    `gorm:"size:10;not null" json:"component"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+}
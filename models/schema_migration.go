@@ -0,0 +1,8 @@
+package models
+
+import "time"
+
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	AppliedAt time.Time `gorm:"autoCreateTime" json:"applied_at"`
+}
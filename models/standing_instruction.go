@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+const (
+	FrequencyDaily     = "daily"
+	FrequencyWeekly    = "weekly"
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+)
+
+const (
+	// pending_approval instructions wait for a checker before they run
+	InstructionPendingApproval = "pending_approval"
+	InstructionActive          = "active"
+	InstructionPaused          = "paused"
+	InstructionCancelled       = "cancelled"
+	// completed instructions ran past their end date
+	InstructionCompleted = "completed"
+)
+
+const (
+	ExecutionSucceeded = "succeeded"
+	ExecutionFailed    = "failed"
+)
+
+// StandingInstruction transfers Amount from one account to another on every occurrence of
+// Frequency between StartDate and EndDate. A failed transfer is retried up to MaxRetries times,
+// RetryIntervalHours apart, before the occurrence is skipped; after PauseAfterFailures failed
+// executions in a row the instruction is paused. CustomerIDs gave the instruction and are checked
+// against the source account's mandate on every execution.
+type StandingInstruction struct {
+	ID                   uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SourceAccountID      uint       `gorm:"not null;index" json:"source_account_id"`
+	SourceAccount        Account    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	DestinationAccountID uint       `gorm:"not null;index" json:"destination_account_id"`
+	DestinationAccount   Account    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Amount               float64    `gorm:"not null" json:"amount"`
+	Description          string     `gorm:"size:255" json:"description"`
+	Frequency            string     `gorm:"size:10;not null" json:"frequency"`
+	StartDate            time.Time  `gorm:"not null" json:"start_date"`
+	EndDate              *time.Time `json:"end_date,omitempty"`
+	CustomerIDs          []uint     `gorm:"serializer:json;type:jsonb;not null" json:"customer_ids"`
+	MaxRetries           int        `gorm:"not null;default:2" json:"max_retries"`
+	RetryIntervalHours   int        `gorm:"not null;default:4" json:"retry_interval_hours"`
+	PauseAfterFailures   int        `gorm:"not null;default:3" json:"pause_after_failures"`
+	Status               string     `gorm:"size:20;not null;default:active;index" json:"status"`
+	// ApprovalID is the approval request instructions at or above the transfer threshold ran under
+	ApprovalID *uint `gorm:"index" json:"approval_id,omitempty"`
+	// Occurrence counts from 0, NextDueDate is the date of that occurrence
+	Occurrence          int        `gorm:"not null;default:0" json:"occurrence"`
+	NextDueDate         time.Time  `gorm:"not null" json:"next_due_date"`
+	NextRunAt           time.Time  `gorm:"not null;index" json:"next_run_at"`
+	Attempts            int        `gorm:"not null;default:0" json:"attempts"`
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"`
+	PausedReason        string     `gorm:"size:255" json:"paused_reason,omitempty"`
+	LastExecutedAt      *time.Time `json:"last_executed_at,omitempty"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Version             uint       `gorm:"not null;default:1" json:"version"`
+}
+
+// StandingInstructionExecution is one attempt at an occurrence of a standing instruction.
+type StandingInstructionExecution struct {
+	ID                  uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	InstructionID       uint                `gorm:"not null;index" json:"instruction_id"`
+	Instruction         StandingInstruction `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	DueDate             time.Time           `gorm:"not null" json:"due_date"`
+	Attempt             int                 `gorm:"not null" json:"attempt"`
+	Amount              float64             `gorm:"not null" json:"amount"`
+	Status              string              `gorm:"size:10;not null" json:"status"`
+	FailureCode         string              `gorm:"size:50" json:"failure_code,omitempty"`
+	FailureReason       string              `gorm:"size:255" json:"failure_reason,omitempty"`
+	DebitTransactionID  *uint               `json:"debit_transaction_id,omitempty"`
+	CreditTransactionID *uint               `json:"credit_transaction_id,omitempty"`
+	ExecutedAt          time.Time           `gorm:"not null" json:"executed_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// WithdrawalLimits caps money leaving an account, nil fields are not limited.
+type WithdrawalLimits struct {
+	MaxSingleWithdrawal    *float64 `json:"max_single_withdrawal"`
+	DailyWithdrawalTotal   *float64 `json:"daily_withdrawal_total"`
+	MonthlyWithdrawalTotal *float64 `json:"monthly_withdrawal_total"`
+	DailyWithdrawalCount   *int     `json:"daily_withdrawal_count"`
+}
+
+// TransactionLimit holds the limits set for either an account type or a single account. An
+// account's own limits override its type's field by field.
+type TransactionLimit struct {
+	ID               uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountType      *string  `gorm:"size:20;uniqueIndex" json:"account_type,omitempty"`
+	AccountID        *uint    `gorm:"uniqueIndex" json:"account_id,omitempty"`
+	Account          *Account `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	WithdrawalLimits `gorm:"embedded"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	Version          uint      `gorm:"not null;default:1" json:"version"`
+}
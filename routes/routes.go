@@ -1,31 +1,126 @@
 package routes
 
 import (
+	"time"
+
+	"banking_system/config"
 	"banking_system/controllers"
+	"banking_system/middleware"
 	"banking_system/services"
+	"banking_system/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	router := gin.Default()
+// Services are built once and shared by the router and the background jobs, so both run on the
+// same policies and approval hooks.
+type Services struct {
+	Approval            *services.ApprovalService
+	Fraud               *services.FraudService
+	Bank                *services.BankService
+	Branch              *services.BranchService
+	Customer            *services.CustomerService
+	KYC                 *services.KYCService
+	Account             *services.AccountService
+	Limit               *services.LimitService
+	Loan                *services.LoanService
+	Repayment           *services.RepaymentService
+	Transaction         *services.TransactionService
+	Deposit             *services.DepositService
+	StandingInstruction *services.StandingInstructionService
+	BenchmarkRate       *services.BenchmarkRateService
+	LoanProduct         *services.LoanProductService
+	Collateral          *services.CollateralService
+	Guarantor           *services.GuarantorService
+	LoanApplication     *services.LoanApplicationService
+	Health              *services.HealthService
+}
 
+// NewServices builds the services from the policies configured in the environment.
+func NewServices(db *gorm.DB) *Services {
+	approvalService := services.NewApprovalService(db, approvalPolicy())
+	fraudService := services.NewFraudService(db, fraudPolicy())
 	bankService := services.NewBankService(db)
 	branchService := services.NewBranchService(db)
-	customerService := services.NewCustomerService(db)
-	accountService := services.NewAccountService(db)
-	loanService := services.NewLoanService(db)
-	repaymentService := services.NewRepaymentService(db)
+	customerService := services.NewCustomerService(db, config.GetEnvList("CUSTOMER_MERGE_ROLES", []string{"compliance_officer", "manager"}))
+	kycService := services.NewKYCService(db, storage.NewLocalStore(config.GetEnvString("DOCUMENT_STORAGE_DIR", "./data/documents")), kycPolicy())
+	accountService := services.NewAccountService(db, approvalService)
+	limitService := services.NewLimitService(db, approvalService)
+	underwriting := underwritingPolicy()
+	loanService := services.NewLoanService(db, underwriting.OfficerRoles)
+	repaymentService := services.NewRepaymentService(db, loanService)
 	transactionService := services.NewTransactionService(db)
+	depositService := services.NewDepositService(db, depositRates())
+	standingInstructionService := services.NewStandingInstructionService(db, accountService, approvalService)
+	benchmarkRateService := services.NewBenchmarkRateService(db)
+	loanProductService := services.NewLoanProductService(db)
+	collateralService := services.NewCollateralService(db)
+	guarantorService := services.NewGuarantorService(db)
+	loanApplicationService := services.NewLoanApplicationService(db, loanService, customerService, approvalService, underwriting)
+	healthService := services.NewHealthService(db)
+
+	return &Services{
+		Approval:            approvalService,
+		Fraud:               fraudService,
+		Bank:                bankService,
+		Branch:              branchService,
+		Customer:            customerService,
+		KYC:                 kycService,
+		Account:             accountService,
+		Limit:               limitService,
+		Loan:                loanService,
+		Repayment:           repaymentService,
+		Transaction:         transactionService,
+		Deposit:             depositService,
+		StandingInstruction: standingInstructionService,
+		BenchmarkRate:       benchmarkRateService,
+		LoanProduct:         loanProductService,
+		Collateral:          collateralService,
+		Guarantor:           guarantorService,
+		LoanApplication:     loanApplicationService,
+		Health:              healthService,
+	}
+}
+
+func SetupRouter(svc *Services) *gin.Engine {
+	controllers.RegisterValidators()
+
+	router := gin.New()
+	router.Use(
+		middleware.RequestID(),
+		middleware.Actor(),
+		middleware.AccessLog(),
+		//metrics wrap recovery and error rendering so they see the final status
+		middleware.Metrics(),
+		middleware.Recovery(),
+		middleware.ErrorHandler(),
+	)
 
-	bankController := controllers.NewBankController(bankService)
-	branchController := controllers.NewBranchController(branchService)
-	customerController := controllers.NewCustomerController(customerService)
-	accountController := controllers.NewAccountController(accountService)
-	loanController := controllers.NewLoanController(loanService)
-	repaymentController := controllers.NewRepaymentController(repaymentService)
-	transactionController := controllers.NewTransactionController(transactionService)
+	bankController := controllers.NewBankController(svc.Bank)
+	branchController := controllers.NewBranchController(svc.Branch)
+	customerController := controllers.NewCustomerController(svc.Customer)
+	kycController := controllers.NewKYCController(svc.KYC)
+	accountController := controllers.NewAccountController(svc.Account)
+	limitController := controllers.NewLimitController(svc.Limit)
+	loanController := controllers.NewLoanController(svc.Loan)
+	loanApplicationController := controllers.NewLoanApplicationController(svc.LoanApplication)
+	benchmarkRateController := controllers.NewBenchmarkRateController(svc.BenchmarkRate)
+	loanProductController := controllers.NewLoanProductController(svc.LoanProduct)
+	collateralController := controllers.NewCollateralController(svc.Collateral)
+	guarantorController := controllers.NewGuarantorController(svc.Guarantor)
+	repaymentController := controllers.NewRepaymentController(svc.Repayment)
+	transactionController := controllers.NewTransactionController(svc.Transaction)
+	depositController := controllers.NewDepositController(svc.Deposit)
+	standingInstructionController := controllers.NewStandingInstructionController(svc.StandingInstruction)
+	healthController := controllers.NewHealthController(svc.Health)
+	approvalController := controllers.NewApprovalController(svc.Approval)
+	fraudAlertController := controllers.NewFraudAlertController(svc.Fraud)
+
+	router.GET("/healthz", healthController.Liveness)
+	router.GET("/readyz", healthController.Readiness)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	banks := router.Group("/banks")
 	{
@@ -33,6 +128,7 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		banks.GET("", bankController.GetAllBanks)
 		banks.GET("/:id", bankController.GetBankByID)
 		banks.PUT("/:id", bankController.UpdateBank)
+		banks.PATCH("/:id", bankController.UpdateBank)
 		banks.DELETE("/:id", bankController.DeleteBank)
 	}
 
@@ -41,7 +137,9 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		branches.POST("", branchController.CreateBranch)
 		branches.GET("", branchController.GetAllBranches)
 		branches.GET("/:id", branchController.GetBranchByID)
+		branches.GET("/by-routing-code/:code", branchController.GetBranchByRoutingCode)
 		branches.PUT("/:id", branchController.UpdateBranch)
+		branches.PATCH("/:id", branchController.UpdateBranch)
 		branches.DELETE("/:id", branchController.DeleteBranch)
 	}
 
@@ -49,12 +147,26 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	{
 		customers.POST("", customerController.CreateCustomer)
 		customers.GET("", customerController.GetAllCustomers)
+		customers.GET("/duplicates", customerController.FindDuplicateCustomers)
 		customers.GET("/:id", customerController.GetCustomerByID)
 		customers.PUT("/:id", customerController.UpdateCustomer)
+		customers.PATCH("/:id", customerController.UpdateCustomer)
 		customers.DELETE("/:id", customerController.DeleteCustomer)
+		customers.POST("/:id/restore", customerController.RestoreCustomer)
+		customers.POST("/:id/merge", customerController.MergeCustomer)
+		customers.GET("/:id/merges", customerController.GetCustomerMerges)
 
 		customers.GET("/:id/accounts", customerController.GetCustomerAccounts)
 		customers.GET("/:id/loans", customerController.GetCustomerLoans)
+
+		customers.GET("/:id/kyc", kycController.GetKYCProfile)
+		customers.POST("/:id/kyc/verify", kycController.VerifyKYC)
+		customers.POST("/:id/kyc/reject", kycController.RejectKYC)
+		customers.POST("/:id/addresses", kycController.AddAddress)
+		customers.DELETE("/:id/addresses/:addressId", kycController.DeleteAddress)
+		customers.POST("/:id/identities", kycController.AddIdentity)
+		customers.POST("/:id/documents", kycController.UploadDocument)
+		customers.GET("/:id/documents/:documentId/content", kycController.DownloadDocument)
 	}
 
 	accounts := router.Group("/accounts")
@@ -62,28 +174,89 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		accounts.POST("", accountController.CreateAccount)
 		accounts.GET("", accountController.GetAllAccounts)
 		accounts.GET("/:id", accountController.GetAccountByID)
+		accounts.GET("/by-number/:number", accountController.GetAccountByNumber)
+		accounts.GET("/by-iban/:iban", accountController.GetAccountByIBAN)
 		accounts.PUT("/:id", accountController.UpdateAccount)
+		accounts.PATCH("/:id", accountController.UpdateAccount)
 		accounts.DELETE("/:id", accountController.DeleteAccount)
+		accounts.POST("/:id/restore", accountController.RestoreAccount)
 
 		accounts.POST("/:id/customers/:customerId", accountController.AddCustomerToAccount)
+		accounts.PATCH("/:id/customers/:customerId", accountController.UpdateAccountCustomerRole)
 		accounts.DELETE("/:id/customers/:customerId", accountController.RemoveCustomerFromAccount)
 
 		accounts.GET("/:id/transactions", accountController.GetAccountTransactions)
 
 		accounts.POST("/:id/deposit", accountController.Deposit)
 		accounts.POST("/:id/withdraw", accountController.Withdraw)
+		accounts.POST("/:id/transfers", accountController.Transfer)
+		accounts.GET("/:id/limits", limitController.GetAccountLimits)
+		accounts.PUT("/:id/limits", limitController.SetAccountLimits)
+
+		accounts.POST("/:id/freeze", accountController.FreezeAccount)
+		accounts.POST("/:id/unfreeze", accountController.UnfreezeAccount)
+		accounts.POST("/:id/reactivate", accountController.ReactivateAccount)
+		accounts.POST("/:id/close", accountController.CloseAccount)
+		accounts.GET("/:id/status-history", accountController.GetAccountStatusHistory)
 	}
 
 	loans := router.Group("/loans")
 	{
-		loans.POST("", loanController.CreateLoan)
 		loans.GET("", loanController.GetAllLoans)
 		loans.GET("/:id", loanController.GetLoanByID)
-		loans.PUT("/:id", loanController.UpdateLoan)
 		loans.DELETE("/:id", loanController.DeleteLoan)
+		loans.POST("/:id/restore", loanController.RestoreLoan)
 
 		loans.GET("/:id/details", loanController.GetLoanDetails)
 		loans.POST("/:id/repay", loanController.RepayLoan)
+		loans.GET("/:id/schedule", loanController.GetLoanSchedule)
+		loans.GET("/:id/payoff", loanController.GetLoanPayoff)
+		loans.POST("/:id/prepay", loanController.PrepayLoan)
+		loans.POST("/:id/foreclose", loanController.ForecloseLoan)
+		loans.POST("/:id/restructure", loanController.RestructureLoan)
+		loans.GET("/:id/terms", loanController.GetLoanTerms)
+
+		loans.GET("/:id/collateral", collateralController.GetLoanCollateral)
+		loans.POST("/:id/collateral", collateralController.AddLoanCollateral)
+		loans.PATCH("/:id/collateral/:collateralId", collateralController.RevalueCollateral)
+		loans.POST("/:id/collateral/:collateralId/release", collateralController.ReleaseCollateral)
+		loans.GET("/:id/guarantors", guarantorController.GetLoanGuarantors)
+		loans.POST("/:id/guarantors", guarantorController.AddLoanGuarantor)
+		loans.POST("/:id/guarantors/:guarantorId/release", guarantorController.ReleaseGuarantor)
+	}
+
+	benchmarkRates := router.Group("/benchmark-rates")
+	{
+		benchmarkRates.POST("", benchmarkRateController.CreateBenchmarkRate)
+		benchmarkRates.GET("", benchmarkRateController.GetAllBenchmarkRates)
+		benchmarkRates.GET("/:id", benchmarkRateController.GetBenchmarkRateByID)
+		benchmarkRates.PUT("/:id", benchmarkRateController.UpdateBenchmarkRate)
+		benchmarkRates.PATCH("/:id", benchmarkRateController.UpdateBenchmarkRate)
+		benchmarkRates.DELETE("/:id", benchmarkRateController.DeleteBenchmarkRate)
+	}
+
+	loanProducts := router.Group("/loan-products")
+	{
+		loanProducts.POST("", loanProductController.CreateLoanProduct)
+		loanProducts.GET("", loanProductController.GetAllLoanProducts)
+		loanProducts.GET("/:id", loanProductController.GetLoanProductByID)
+		loanProducts.PUT("/:id", loanProductController.UpdateLoanProduct)
+		loanProducts.PATCH("/:id", loanProductController.UpdateLoanProduct)
+		loanProducts.DELETE("/:id", loanProductController.DeleteLoanProduct)
+	}
+
+	loanApplications := router.Group("/loan-applications")
+	{
+		loanApplications.POST("", loanApplicationController.CreateLoanApplication)
+		loanApplications.GET("", loanApplicationController.GetAllLoanApplications)
+		loanApplications.GET("/:id", loanApplicationController.GetLoanApplicationByID)
+		loanApplications.POST("/:id/approve", loanApplicationController.ApproveLoanApplication)
+		loanApplications.POST("/:id/reject", loanApplicationController.RejectLoanApplication)
+
+		loanApplications.GET("/:id/collateral", collateralController.GetApplicationCollateral)
+		loanApplications.POST("/:id/collateral", collateralController.PledgeCollateral)
+		loanApplications.GET("/:id/guarantors", guarantorController.GetApplicationGuarantors)
+		loanApplications.POST("/:id/guarantors", guarantorController.OfferGuarantor)
 	}
 
 	repayments := router.Group("/repayments")
@@ -91,18 +264,125 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 		repayments.POST("", repaymentController.CreateRepayment)
 		repayments.GET("", repaymentController.GetAllRepayments)
 		repayments.GET("/:id", repaymentController.GetRepaymentByID)
-		repayments.PUT("/:id", repaymentController.UpdateRepayment)
-		repayments.DELETE("/:id", repaymentController.DeleteRepayment)
 	}
 
 	transactions := router.Group("/transactions")
 	{
-		transactions.POST("", transactionController.CreateTransaction)
 		transactions.GET("", transactionController.GetAllTransactions)
 		transactions.GET("/:id", transactionController.GetTransactionByID)
-		transactions.PUT("/:id", transactionController.UpdateTransaction)
-		transactions.DELETE("/:id", transactionController.DeleteTransaction)
 	}
+
+	accountTypes := router.Group("/account-types")
+	{
+		accountTypes.GET("/:type/limits", limitController.GetAccountTypeLimits)
+		accountTypes.PUT("/:type/limits", limitController.SetAccountTypeLimits)
+	}
+
+	fixedDeposits := router.Group("/fixed-deposits")
+	{
+		fixedDeposits.POST("", depositController.OpenFixedDeposit)
+		fixedDeposits.GET("", depositController.GetAllFixedDeposits)
+		fixedDeposits.GET("/:id", depositController.GetFixedDepositByID)
+		fixedDeposits.POST("/:id/maturity-instruction", depositController.ChangeMaturityInstruction)
+	}
+
+	recurringDeposits := router.Group("/recurring-deposits")
+	{
+		recurringDeposits.POST("", depositController.OpenRecurringDeposit)
+		recurringDeposits.GET("", depositController.GetAllRecurringDeposits)
+		recurringDeposits.GET("/:id", depositController.GetRecurringDepositByID)
+		recurringDeposits.GET("/:id/installments", depositController.GetRecurringDepositInstallments)
+	}
+
+	standingInstructions := router.Group("/standing-instructions")
+	{
+		standingInstructions.POST("", standingInstructionController.CreateStandingInstruction)
+		standingInstructions.GET("", standingInstructionController.GetAllStandingInstructions)
+		standingInstructions.GET("/:id", standingInstructionController.GetStandingInstructionByID)
+		standingInstructions.GET("/:id/executions", standingInstructionController.GetStandingInstructionExecutions)
+		standingInstructions.POST("/:id/pause", standingInstructionController.PauseStandingInstruction)
+		standingInstructions.POST("/:id/resume", standingInstructionController.ResumeStandingInstruction)
+		standingInstructions.POST("/:id/cancel", standingInstructionController.CancelStandingInstruction)
+	}
+
+	approvals := router.Group("/approvals")
+	{
+		approvals.GET("", approvalController.GetAllApprovals)
+		approvals.GET("/:id", approvalController.GetApprovalByID)
+		approvals.POST("/:id/approve", approvalController.Approve)
+		approvals.POST("/:id/reject", approvalController.Reject)
+	}
+
+	fraudAlerts := router.Group("/fraud-alerts")
+	{
+		fraudAlerts.GET("", fraudAlertController.GetAllFraudAlerts)
+		fraudAlerts.GET("/:id", fraudAlertController.GetFraudAlertByID)
+		fraudAlerts.POST("/:id/assign", fraudAlertController.AssignFraudAlert)
+		fraudAlerts.POST("/:id/resolve", fraudAlertController.ResolveFraudAlert)
+	}
+
 	return router
 }
 
+// approvalPolicy reads the maker-checker thresholds. Operations at or above their threshold wait
+// for a second person, a threshold of 0 means the operation always needs approval.
+func approvalPolicy() services.ApprovalPolicy {
+	transferThreshold := config.GetEnvFloat("APPROVAL_TRANSFER_THRESHOLD", 100000)
+	return services.ApprovalPolicy{
+		Thresholds: map[string]float64{
+			services.OpWithdrawal:   config.GetEnvFloat("APPROVAL_WITHDRAWAL_THRESHOLD", 100000),
+			services.OpLoanApproval: config.GetEnvFloat("APPROVAL_LOAN_THRESHOLD", 500000),
+			services.OpRemoveHolder: 0,
+			services.OpLimitChange:  0,
+			services.OpTransfer:     transferThreshold,
+			//standing instructions are held at the same amount as the transfers they repeat
+			services.OpStandingInstruction: transferThreshold,
+		},
+		ApproverRoles: config.GetEnvList("APPROVER_ROLES", []string{"supervisor", "manager"}),
+	}
+}
+
+func fraudPolicy() services.FraudPolicy {
+	return services.FraudPolicy{
+		StructuringThreshold:     config.GetEnvFloat("FRAUD_STRUCTURING_THRESHOLD", 10000),
+		StructuringMarginPercent: config.GetEnvFloat("FRAUD_STRUCTURING_MARGIN_PERCENT", 10),
+		StructuringCount:         config.GetEnvInt("FRAUD_STRUCTURING_COUNT", 3),
+		StructuringWindow:        config.GetEnvDuration("FRAUD_STRUCTURING_WINDOW", 72*time.Hour),
+		RapidWindow:              config.GetEnvDuration("FRAUD_RAPID_WINDOW", 24*time.Hour),
+		RapidMinAmount:           config.GetEnvFloat("FRAUD_RAPID_MIN_AMOUNT", 10000),
+		RapidOutPercent:          config.GetEnvFloat("FRAUD_RAPID_OUT_PERCENT", 80),
+		LargeWithdrawalMultiple:  config.GetEnvFloat("FRAUD_LARGE_WITHDRAWAL_MULTIPLE", 5),
+		LargeLookbackDays:        config.GetEnvInt("FRAUD_LARGE_LOOKBACK_DAYS", 90),
+		LargeMinHistory:          config.GetEnvInt("FRAUD_LARGE_MIN_HISTORY", 3),
+		DormantWindow:            config.GetEnvDuration("FRAUD_DORMANT_WINDOW", 30*24*time.Hour),
+		AutoFreeze:               config.GetEnvBool("FRAUD_AUTO_FREEZE", true),
+		InvestigatorRoles:        config.GetEnvList("FRAUD_INVESTIGATOR_ROLES", []string{"fraud_analyst", "manager"}),
+	}
+}
+
+// kycPolicy reads who may review KYC and how long a verification stays valid.
+func kycPolicy() services.KYCPolicy {
+	return services.KYCPolicy{
+		ReviewerRoles:    config.GetEnvList("KYC_REVIEWER_ROLES", []string{"compliance_officer", "manager"}),
+		ValidityMonths:   config.GetEnvInt("KYC_VALIDITY_MONTHS", 24),
+		MaxDocumentBytes: int64(config.GetEnvInt("KYC_MAX_DOCUMENT_BYTES", 10<<20)),
+	}
+}
+
+// depositRates reads the deposit rate cards, keyed by the shortest tenure in months of each band.
+func depositRates() services.DepositRates {
+	return services.DepositRates{
+		Fixed:     config.GetEnvRates("FIXED_DEPOSIT_RATES", services.DefaultFixedDepositRates),
+		Recurring: config.GetEnvRates("RECURRING_DEPOSIT_RATES", services.DefaultRecurringDepositRates),
+	}
+}
+
+func underwritingPolicy() services.UnderwritingPolicy {
+	return services.UnderwritingPolicy{
+		MaxDebtToIncome:     config.GetEnvFloat("LOAN_MAX_DEBT_TO_INCOME", 0.5),
+		MaxExposureMultiple: config.GetEnvFloat("LOAN_MAX_EXPOSURE_MULTIPLE", 60),
+		BalanceLookbackDays: config.GetEnvInt("LOAN_BALANCE_LOOKBACK_DAYS", 90),
+		OfficerRoles:        config.GetEnvList("LOAN_OFFICER_ROLES", []string{"loan_officer", "manager"}),
+		ValuationMaxAgeDays: config.GetEnvInt("COLLATERAL_VALUATION_MAX_AGE_DAYS", 365),
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// accountTransitions lists the lifecycle moves an account may make, closed is terminal
+var accountTransitions = map[string][]string{
+	models.AccountStatusOpen:    {models.AccountStatusFrozen, models.AccountStatusDormant, models.AccountStatusClosed},
+	models.AccountStatusFrozen:  {models.AccountStatusOpen},
+	models.AccountStatusDormant: {models.AccountStatusOpen, models.AccountStatusFrozen, models.AccountStatusClosed},
+}
+
+func canTransition(from, to string) bool {
+	for _, allowed := range accountTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func lockAccount(tx *gorm.DB, accountID uint) (*models.Account, error) {
+	var account models.Account
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, accountID).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	return &account, nil
+}
+
+// transitionStatus moves a locked account to a new status and records the change.
+func transitionStatus(tx *gorm.DB, account *models.Account, to, reason string) error {
+	if !canTransition(account.Status, to) {
+		return ConflictError("invalid_status_transition", "account cannot move from "+account.Status+" to "+to)
+	}
+
+	change := models.AccountStatusChange{
+		AccountID:  account.ID,
+		FromStatus: account.Status,
+		ToStatus:   to,
+		Reason:     reason,
+	}
+
+	account.Status = to
+	account.StatusReason = reason
+	account.Version++
+	if err := tx.Model(account).Updates(map[string]interface{}{
+		"status":        account.Status,
+		"status_reason": account.StatusReason,
+		"version":       account.Version,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&change).Error
+}
+
+func accountStatusError(account *models.Account) error {
+	return ConflictError("account_"+account.Status, "account is "+account.Status)
+}
+
+// ensureCanCredit rejects credits to frozen or closed accounts. A credit to a dormant account
+// reactivates it.
+func ensureCanCredit(tx *gorm.DB, account *models.Account) error {
+	switch account.Status {
+	case models.AccountStatusOpen:
+		return nil
+	case models.AccountStatusDormant:
+		return transitionStatus(tx, account, models.AccountStatusOpen, "reactivated by deposit")
+	}
+	return accountStatusError(account)
+}
+
+// ensureCanDebit only lets money leave open accounts.
+func ensureCanDebit(account *models.Account) error {
+	if account.Status != models.AccountStatusOpen {
+		return accountStatusError(account)
+	}
+	return nil
+}
+
+func (s *AccountService) changeStatus(ctx context.Context, accountID uint, to, reason string) (*models.Account, error) {
+	var account *models.Account
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		account = locked
+		return transitionStatus(tx, account, to, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("account status changed", "account_id", accountID, "status", to, "reason", reason)
+	return account, nil
+}
+
+func (s *AccountService) Freeze(ctx context.Context, accountID uint, reason string) (*models.Account, error) {
+	return s.changeStatus(ctx, accountID, models.AccountStatusFrozen, reason)
+}
+
+func (s *AccountService) Unfreeze(ctx context.Context, accountID uint, reason string) (*models.Account, error) {
+	return s.changeStatus(ctx, accountID, models.AccountStatusOpen, reason)
+}
+
+func (s *AccountService) Reactivate(ctx context.Context, accountID uint, reason string) (*models.Account, error) {
+	var account models.Account
+	if err := s.db.WithContext(ctx).First(&account, accountID).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	if account.Status != models.AccountStatusDormant {
+		return nil, ConflictError("account_not_dormant", "only dormant accounts can be reactivated")
+	}
+	return s.changeStatus(ctx, accountID, models.AccountStatusOpen, reason)
+}
+
+// Close pays out the remaining balance and closes the account in one transaction.
+func (s *AccountService) Close(ctx context.Context, accountID uint, reason string) (*models.Account, *models.Transaction, error) {
+	var account *models.Account
+	var payout *models.Transaction
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		account = locked
+
+		if !canTransition(account.Status, models.AccountStatusClosed) {
+			return accountStatusError(account)
+		}
+		if err := checkNoCommitments(tx, account.ID); err != nil {
+			return err
+		}
+
+		if account.Balance > 0 {
+			payout, err = postDebit(tx, account, "payout", account.Balance, "closing balance payout")
+			if err != nil {
+				return err
+			}
+		}
+
+		return transitionStatus(tx, account, models.AccountStatusClosed, reason)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logging.FromContext(ctx).Info("account closed", "account_id", accountID, "reason", reason)
+	return account, payout, nil
+}
+
+// checkNoCommitments refuses to close an account that still funds or receives a running deposit
+// or standing instruction.
+func checkNoCommitments(tx *gorm.DB, accountID uint) error {
+	var count int64
+	if err := tx.Model(&models.FixedDeposit{}).
+		Where("account_id = ? AND status = ?", accountID, models.DepositActive).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ConflictError("account_has_deposits", "the account has active fixed deposits")
+	}
+	if err := tx.Model(&models.RecurringDeposit{}).
+		Where("account_id = ? AND status = ?", accountID, models.DepositActive).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ConflictError("account_has_deposits", "the account has active recurring deposits")
+	}
+	if err := tx.Model(&models.StandingInstruction{}).
+		Where("(source_account_id = ? OR destination_account_id = ?) AND status NOT IN ?", accountID, accountID,
+			[]string{models.InstructionCancelled, models.InstructionCompleted}).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ConflictError("account_has_standing_instructions", "standing instructions still pay from or into the account, cancel them first")
+	}
+	return nil
+}
+
+// MarkDormant moves open accounts without any transaction since the cutoff to dormant and
+// returns how many were changed.
+func (s *AccountService) MarkDormant(ctx context.Context, inactiveSince time.Time) (int, error) {
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(&models.Account{}).
+		Where("status = ? AND created_at < ?", models.AccountStatusOpen, inactiveSince).
+		Where("NOT EXISTS (SELECT 1 FROM transactions WHERE transactions.account_id = accounts.id AND transactions.transaction_date >= ?)", inactiveSince).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, id := range ids {
+		marked := false
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			account, err := lockAccount(tx, id)
+			if err != nil {
+				return err
+			}
+			// re-check under the lock, a transaction may have landed since the scan
+			if account.Status != models.AccountStatusOpen {
+				return nil
+			}
+			var recent int64
+			if err := tx.Model(&models.Transaction{}).
+				Where("account_id = ? AND transaction_date >= ?", id, inactiveSince).
+				Count(&recent).Error; err != nil {
+				return err
+			}
+			if recent > 0 {
+				return nil
+			}
+			if err := transitionStatus(tx, account, models.AccountStatusDormant, "no activity since "+inactiveSince.Format("2006-01-02")); err != nil {
+				return err
+			}
+			marked = true
+			return nil
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to mark account dormant", "account_id", id, "error", err)
+			continue
+		}
+		//only count once the transaction has committed
+		if marked {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func (s *AccountService) GetStatusHistory(ctx context.Context, accountID uint) ([]models.AccountStatusChange, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Account{}, accountID, "account_id", "account"); err != nil {
+		return nil, err
+	}
+	var history []models.AccountStatusChange
+	if err := s.db.WithContext(ctx).Where("account_id = ?", accountID).Order("id asc").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
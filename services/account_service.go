@@ -1,9 +1,15 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"banking_system/identifiers"
+	"banking_system/logging"
+	"banking_system/metrics"
 	"banking_system/models"
 
 	"gorm.io/gorm"
@@ -11,45 +17,166 @@ import (
 )
 
 type AccountService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	approvals *ApprovalService
 }
 
-func NewAccountService(db *gorm.DB) *AccountService {
-	return &AccountService{db: db}
+// NewAccountService registers the account operations that can wait for approval. Without an
+// approval service (background jobs) nothing is held back.
+func NewAccountService(db *gorm.DB, approvals *ApprovalService) *AccountService {
+	s := &AccountService{db: db, approvals: approvals}
+	if approvals != nil {
+		approvals.Register(OpWithdrawal, s.executeWithdrawal)
+		approvals.Register(OpRemoveHolder, s.executeRemoveHolder)
+		approvals.Register(OpTransfer, s.executeTransfer)
+	}
+	return s
+}
+
+// withDB returns a copy of the service working in tx, approved operations run in the approval's
+// transaction.
+func (s *AccountService) withDB(tx *gorm.DB) *AccountService {
+	copied := *s
+	copied.db = tx
+	return &copied
 }
 
-func (s *AccountService) Create(account *models.Account) error {
-	return s.db.Create(account).Error
+// Create assigns the account number from the branch code and the branch's next sequence, both in
+// the same transaction so concurrent openings never collide.
+func (s *AccountService) Create(ctx context.Context, account *models.Account) error {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Branch{}, account.BranchID, "branch_id", "branch"); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		number, err := nextAccountNumber(tx, account.BranchID)
+		if err != nil {
+			return err
+		}
+		account.AccountNumber = number
+		return dbError(tx.Create(account).Error, "account")
+	})
 }
 
-func (s *AccountService) GetByID(id uint) (*models.Account, error) {
+func nextAccountNumber(tx *gorm.DB, branchID uint) (string, error) {
+	var branch models.Branch
+	if err := tx.First(&branch, branchID).Error; err != nil {
+		return "", dbError(err, "branch")
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.AccountSequence{BranchID: branchID}).Error; err != nil {
+		return "", err
+	}
+	var seq models.AccountSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "branch_id = ?", branchID).Error; err != nil {
+		return "", err
+	}
+	seq.LastValue++
+	if err := tx.Model(&seq).Update("last_value", seq.LastValue).Error; err != nil {
+		return "", err
+	}
+
+	number, err := identifiers.AccountNumber(branch.Code, seq.LastValue)
+	if err != nil {
+		return "", ConflictError("branch_code_missing", "branch needs a code before accounts can be opened")
+	}
+	return number, nil
+}
+
+func (s *AccountService) GetByID(ctx context.Context, id uint, includeDeleted bool) (*models.Account, error) {
 	var account models.Account
-	if err := s.db.First(&account, id).Error; err != nil {
-		return nil, err
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).First(&account, id).Error; err != nil {
+		return nil, dbError(err, "account")
 	}
 	return &account, nil
 }
 
-func (s *AccountService) GetAccountDetail(id uint) (*models.AccountDetail, error) {
+func (s *AccountService) GetAccountDetail(ctx context.Context, id uint, includeDeleted bool) (*models.AccountDetail, error) {
 	var account models.Account
-	if err := s.db.First(&account, id).Error; err != nil {
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).First(&account, id).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	return s.accountDetail(ctx, &account)
+}
+
+// GetByNumber looks an account up by its number, rejecting numbers whose check digits are wrong
+// before touching the database.
+func (s *AccountService) GetByNumber(ctx context.Context, number string, includeDeleted bool) (*models.AccountDetail, error) {
+	number = identifiers.Normalize(number)
+	if !identifiers.ValidAccountNumber(number) {
+		return nil, ValidationError("invalid_account_number", "account number check digits do not match")
+	}
+
+	var account models.Account
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).Where("account_number = ?", number).First(&account).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	return s.accountDetail(ctx, &account)
+}
+
+// GetByIBAN resolves an IBAN built by accountIBAN back to the account.
+func (s *AccountService) GetByIBAN(ctx context.Context, iban string, includeDeleted bool) (*models.AccountDetail, error) {
+	iban = identifiers.Normalize(iban)
+	if !identifiers.ValidIBAN(iban) {
+		return nil, ValidationError("invalid_iban", "IBAN format or check digits are invalid")
+	}
+	_, number := identifiers.SplitIBAN(iban)
+
+	var account models.Account
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).Where("account_number = ?", number).First(&account).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	detail, err := s.accountDetail(ctx, &account)
+	if err != nil {
 		return nil, err
 	}
+	//the account number matched but it belongs to a bank in another country
+	if detail.IBAN != iban {
+		return nil, NotFoundError("account_not_found", "account not found")
+	}
+	return detail, nil
+}
+
+// accountIBAN is the bank's country code, check digits and the account number, which already
+// carries the branch routing code.
+func (s *AccountService) accountIBAN(ctx context.Context, account *models.Account) (string, error) {
+	var branch models.Branch
+	if err := s.db.WithContext(ctx).Preload("Bank").First(&branch, account.BranchID).Error; err != nil {
+		return "", err
+	}
+	return identifiers.IBAN(identifiers.CountryCode(branch.Bank.Code), account.AccountNumber)
+}
+
+func (s *AccountService) accountDetail(ctx context.Context, account *models.Account) (*models.AccountDetail, error) {
+	id := account.ID
+
+	iban, err := s.accountIBAN(ctx, account)
+	if err != nil {
+		logging.FromContext(ctx).Warn("could not build iban", "account_id", id, "error", err)
+	}
 
 	var accountCustomers []models.AccountCustomer
-	if err := s.db.Preload("Customer").Where("account_id = ?", id).Find(&accountCustomers).Error; err != nil {
+	//archived customers stay listed on the accounts they held
+	if err := s.db.WithContext(ctx).Preload("Customer", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Where("account_id = ?", id).Find(&accountCustomers).Error; err != nil {
 		return nil, err
 	}
 
 	detail := &models.AccountDetail{
-		ID:            account.ID,
-		AccountNumber: account.AccountNumber,
-		BranchID:      account.BranchID,
-		AccountType:   account.AccountType,
-		Interest:      account.Interest,
-		Balance:       account.Balance,
-		CreatedAt:     account.CreatedAt,
-		Customers:     make([]models.CustomerInfo, 0),
+		ID:               account.ID,
+		AccountNumber:    account.AccountNumber,
+		IBAN:             iban,
+		BranchID:         account.BranchID,
+		AccountType:      account.AccountType,
+		Joint:            account.Joint,
+		Interest:         account.Interest,
+		Balance:          account.Balance,
+		OperatingMandate: account.OperatingMandate,
+		Status:           account.Status,
+		StatusReason:     account.StatusReason,
+		CreatedAt:        account.CreatedAt,
+		Version:          account.Version,
+		Customers:        make([]models.CustomerInfo, 0),
 	}
 
 	for _, ac := range accountCustomers {
@@ -66,170 +193,493 @@ func (s *AccountService) GetAccountDetail(id uint) (*models.AccountDetail, error
 	return detail, nil
 }
 
-func (s *AccountService) GetAll() ([]models.Account, error) {
+func (s *AccountService) GetAll(ctx context.Context, includeDeleted bool) ([]models.Account, error) {
 	var accounts []models.Account
-	if err := s.db.Find(&accounts).Error; err != nil {
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).Find(&accounts).Error; err != nil {
 		return nil, err
 	}
 	return accounts, nil
 }
 
-func (s *AccountService) Update(account *models.Account) error {
-	return s.db.Save(account).Error
+func (s *AccountService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.Account, error) {
+	if err := applyChanges(s.db.WithContext(ctx), &models.Account{}, id, version, changes, "account"); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id, false)
 }
 
-func (s *AccountService) Delete(id uint) error {
-	return s.db.Delete(&models.Account{}, id).Error
+// Delete archives the account, it stays queryable with include_deleted and can be restored.
+func (s *AccountService) Delete(ctx context.Context, id, version uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, id).Error; err != nil {
+			return dbError(err, "account")
+		}
+		if err := checkVersion(account.Version, version, "account"); err != nil {
+			return err
+		}
+		if account.Balance != 0 {
+			return ConflictError("account_balance_not_zero", "account balance must be zero before it can be closed")
+		}
+		return dbError(tx.Delete(&account).Error, "account")
+	})
 }
 
-func (s *AccountService) AddCustomer(accountID, customerID uint) (*models.AccountDetail, error) {
-	var account models.Account
-	if err := s.db.First(&account, accountID).Error; err != nil {
-		return nil, fmt.Errorf("account not found: %w", err)
+func (s *AccountService) Restore(ctx context.Context, id uint) (*models.Account, error) {
+	if err := restoreDeleted(s.db.WithContext(ctx), &models.Account{}, id, "account"); err != nil {
+		return nil, err
 	}
+	return s.GetByID(ctx, id, false)
+}
 
-	var customer models.Customer
-	if err := s.db.First(&customer, customerID).Error; err != nil {
-		return nil, fmt.Errorf("customer not found: %w", err)
+// AddCustomer links a customer to the account with the given role. The first party has to be the
+// primary holder; an empty role picks primary for the first party and joint after that.
+func (s *AccountService) AddCustomer(ctx context.Context, accountID, customerID uint, role string) (*models.AccountDetail, error) {
+	if role != "" && !holderRoles[role] {
+		return nil, ValidationError("invalid_role", "unknown account holder role "+role)
 	}
 
-	//this checks if customer is already linked to this account
-	var existingLink models.AccountCustomer
-	if err := s.db.Where("account_id = ? AND customer_id = ?", accountID, customerID).First(&existingLink).Error; err == nil {
-		return nil, errors.New("customer is already linked to this account")
-	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Status == models.AccountStatusClosed {
+			return accountStatusError(account)
+		}
 
-	var count int64
-	if err := s.db.Model(&models.AccountCustomer{}).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
-		return nil, fmt.Errorf("failed to count existing customers: %w", err)
-	}
+		var customer models.Customer
+		if err := tx.First(&customer, customerID).Error; err != nil {
+			return dbError(err, "customer")
+		}
+		if err := ensureKYCVerified(&customer, time.Now()); err != nil {
+			return err
+		}
+
+		links, err := accountLinks(tx, accountID)
+		if err != nil {
+			return fmt.Errorf("failed to load account holders: %w", err)
+		}
+
+		hasPrimary := false
+		for _, link := range links {
+			//this checks if customer is already linked to this account
+			if link.CustomerID == customerID {
+				return ConflictError("customer_already_linked", "customer is already linked to this account")
+			}
+			if link.Role == models.HolderRolePrimary {
+				hasPrimary = true
+			}
+		}
 
-	//this handles the role based on customer count
-	role := "primary_holder"
-	if count > 0 {
-		role = "joint_holder"
-		// updates account type to 'joint' when adding second customer
-		if err := s.db.Model(&account).Update("account_type", "joint").Error; err != nil {
-			return nil, fmt.Errorf("failed to update account type: %w", err)
+		if role == "" {
+			role = models.HolderRoleJoint
+			if !hasPrimary {
+				role = models.HolderRolePrimary
+			}
 		}
+		if role == models.HolderRolePrimary && hasPrimary {
+			return ConflictError("primary_holder_exists", "account already has a primary holder")
+		}
+		if role != models.HolderRolePrimary && !hasPrimary {
+			return ConflictError("primary_holder_required", "the first customer linked to an account must be its primary holder")
+		}
+
+		link := models.AccountCustomer{
+			AccountID:  accountID,
+			CustomerID: customerID,
+			Role:       role,
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return dbError(fmt.Errorf("failed to add customer: %w", err), "account_customer")
+		}
+		return syncJoint(tx, account)
+	})
+	if err != nil {
+		return nil, err
 	}
+	logging.FromContext(ctx).Info("customer linked to account", "account_id", accountID, "customer_id", customerID, "role", role)
+
+	return s.GetAccountDetail(ctx, accountID, false)
+}
 
-	link := models.AccountCustomer{
-		AccountID:  accountID,
-		CustomerID: customerID,
-		Role:       role,
+// UpdateCustomerRole changes the role of a linked customer. Making someone primary demotes the
+// current primary to joint holder, demoting the primary promotes the longest-standing joint holder.
+func (s *AccountService) UpdateCustomerRole(ctx context.Context, accountID, customerID uint, role string) (*models.AccountDetail, error) {
+	if !holderRoles[role] {
+		return nil, ValidationError("invalid_role", "unknown account holder role "+role)
 	}
 
-	if err := s.db.Create(&link).Error; err != nil {
-		return nil, fmt.Errorf("failed to add customer: %w", err)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+
+		var link models.AccountCustomer
+		if err := tx.Where("account_id = ? AND customer_id = ?", accountID, customerID).First(&link).Error; err != nil {
+			return dbError(err, "account_customer")
+		}
+		if link.Role == role {
+			return nil
+		}
+
+		switch {
+		case role == models.HolderRolePrimary:
+			if err := tx.Model(&models.AccountCustomer{}).
+				Where("account_id = ? AND role = ?", accountID, models.HolderRolePrimary).
+				Update("role", models.HolderRoleJoint).Error; err != nil {
+				return err
+			}
+		case link.Role == models.HolderRolePrimary:
+			if err := promoteSuccessor(tx, accountID, customerID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&link).Update("role", role).Error; err != nil {
+			return err
+		}
+		return syncJoint(tx, account)
+	})
+	if err != nil {
+		return nil, err
 	}
+	logging.FromContext(ctx).Info("account holder role changed", "account_id", accountID, "customer_id", customerID, "role", role)
 
-	return s.GetAccountDetail(accountID)
+	return s.GetAccountDetail(ctx, accountID, false)
 }
 
-func (s *AccountService) RemoveCustomer(accountID, customerID uint) error {
-	var linkCount int64
-	if err := s.db.Model(&models.AccountCustomer{}).Where("account_id = ?", accountID).Count(&linkCount).Error; err != nil {
-		return fmt.Errorf("failed to count customers: %w", err)
+// RemoveCustomer unlinks a customer. Removing the primary holder hands the role to the
+// longest-standing joint holder, and the last holder can never be removed.
+func (s *AccountService) RemoveCustomer(ctx context.Context, accountID, customerID uint) error {
+	if s.approvals.requiresApproval(ctx, OpRemoveHolder, 0) {
+		var link models.AccountCustomer
+		if err := s.db.WithContext(ctx).Where("account_id = ? AND customer_id = ?", accountID, customerID).First(&link).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NotFoundError("account_customer_not_found", "customer is not linked to this account")
+			}
+			return err
+		}
+		//only owners need a second pair of eyes, nominees and signatories are removed directly
+		if isHolder(link.Role) {
+			return s.approvals.submit(ctx, OpRemoveHolder, "account", accountID, 0, removeHolderPayload{AccountID: accountID, CustomerID: customerID})
+		}
 	}
 
-	if err := s.db.Delete(&models.AccountCustomer{}, "account_id = ? AND customer_id = ?", accountID, customerID).Error; err != nil {
-		return err
-	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+
+		var link models.AccountCustomer
+		if err := tx.Where("account_id = ? AND customer_id = ?", accountID, customerID).First(&link).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NotFoundError("account_customer_not_found", "customer is not linked to this account")
+			}
+			return err
+		}
 
-	if linkCount == 2 {
-		if err := s.db.Model(&models.Account{}).Where("id = ?", accountID).Update("account_type", "savings").Error; err != nil {
-			return fmt.Errorf("failed to update account type: %w", err)
+		if link.Role == models.HolderRolePrimary {
+			if err := promoteSuccessor(tx, accountID, customerID); err != nil {
+				return err
+			}
 		}
+
+		if err := tx.Delete(&link).Error; err != nil {
+			return err
+		}
+		return syncJoint(tx, account)
+	})
+	if err != nil {
+		return err
 	}
+	logging.FromContext(ctx).Info("customer unlinked from account", "account_id", accountID, "customer_id", customerID)
 	return nil
 }
 
-func (s *AccountService) GetTransactions(accountID uint) ([]models.Transaction, error) {
+// promoteSuccessor makes the earliest joint holder other than the departing customer primary.
+func promoteSuccessor(tx *gorm.DB, accountID, departingID uint) error {
+	var successor models.AccountCustomer
+	err := tx.Where("account_id = ? AND customer_id <> ? AND role = ?", accountID, departingID, models.HolderRoleJoint).
+		Order("created_at asc, agreement_id asc").First(&successor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ConflictError("account_requires_holder", "the primary holder is the only holder, close the account instead")
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&successor).Update("role", models.HolderRolePrimary).Error
+}
+
+func (s *AccountService) GetTransactions(ctx context.Context, accountID uint) ([]models.Transaction, error) {
 	var txs []models.Transaction
-	if err := s.db.Where("account_id = ?", accountID).Order("created_at asc").Find(&txs).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("account_id = ?", accountID).Order("transaction_date asc").Find(&txs).Error; err != nil {
 		return nil, err
 	}
 	return txs, nil
 }
 
-func (s *AccountService) Deposit(accountID uint, amount float64, description string) (*models.Transaction, error) {
+func (s *AccountService) Deposit(ctx context.Context, accountID uint, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, errors.New("amount must be greater than zero")
+		return nil, ValidationError("invalid_amount", "amount must be greater than zero")
 	}
 
 	var txRecord *models.Transaction
+	var accountType string
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var account models.Account
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, accountID).Error; err != nil {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, accountID)
+		if err != nil {
 			return err
 		}
+		accountType = account.AccountType
 
-		account.Balance += amount
-		if err := tx.Save(&account).Error; err != nil {
+		if err := ensureCanCredit(tx, account); err != nil {
 			return err
 		}
 
-		newTx := models.Transaction{
-			AccountID:   accountID,
-			Type:        "deposit",
-			Amount:      amount,
-			Description: description,
-		}
-		if err := tx.Create(&newTx).Error; err != nil {
-			return err
-		}
-		txRecord = &newTx
-		return nil
+		txRecord, err = postCredit(tx, account, "deposit", amount, description)
+		return err
 	})
 
 	if err != nil {
+		logging.FromContext(ctx).Warn("deposit failed", "account_id", accountID, "amount", amount, "error", err)
 		return nil, err
 	}
 
+	logging.FromContext(ctx).Info("deposit completed", "account_id", accountID, "transaction_id", txRecord.ID, "amount", amount)
+	metrics.Deposits.WithLabelValues(accountType).Inc()
+	metrics.DepositAmount.WithLabelValues(accountType).Add(amount)
 	return txRecord, nil
 }
 
-func (s *AccountService) Withdraw(accountID uint, amount float64, description string) (*models.Transaction, error) {
+// Withdraw debits the account on the instruction of the given customers, who have to satisfy the
+// account's operating mandate.
+func (s *AccountService) Withdraw(ctx context.Context, accountID uint, amount float64, description string, customerIDs []uint) (*models.Transaction, error) {
 	if amount <= 0 {
-		return nil, errors.New("amount must be greater than zero")
+		return nil, ValidationError("invalid_amount", "amount must be greater than zero")
 	}
 
-	var txRecord *models.Transaction
-
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	if s.approvals.requiresApproval(ctx, OpWithdrawal, amount) {
+		//reject what would fail anyway before bothering a checker
 		var account models.Account
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, accountID).Error; err != nil {
-			return err
+		if err := s.db.WithContext(ctx).First(&account, accountID).Error; err != nil {
+			return nil, dbError(err, "account")
 		}
-
-		if account.Balance < amount {
-			return errors.New("insufficient balance")
+		if err := ensureCanDebit(&account); err != nil {
+			return nil, err
 		}
-
-		account.Balance -= amount
-		if err := tx.Save(&account).Error; err != nil {
-			return err
+		if err := checkMandate(s.db.WithContext(ctx), &account, customerIDs); err != nil {
+			return nil, err
 		}
-
-		newTx := models.Transaction{
+		if err := checkLimits(s.db.WithContext(ctx), &account, amount, time.Now()); err != nil {
+			return nil, err
+		}
+		return nil, s.approvals.submit(ctx, OpWithdrawal, "account", accountID, amount, withdrawalPayload{
 			AccountID:   accountID,
-			Type:        "withdrawal",
 			Amount:      amount,
 			Description: description,
+			CustomerIDs: customerIDs,
+		})
+	}
+
+	var txRecord *models.Transaction
+	var accountType string
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
 		}
-		if err := tx.Create(&newTx).Error; err != nil {
+		accountType = account.AccountType
+
+		if err := ensureCanDebit(account); err != nil {
+			metrics.FailedWithdrawals.WithLabelValues(account.AccountType, "account_"+account.Status).Inc()
 			return err
 		}
-		txRecord = &newTx
-		return nil
+		if err := checkMandate(tx, account, customerIDs); err != nil {
+			metrics.FailedWithdrawals.WithLabelValues(account.AccountType, "mandate").Inc()
+			return err
+		}
+		if err := checkLimits(tx, account, amount, time.Now()); err != nil {
+			metrics.FailedWithdrawals.WithLabelValues(account.AccountType, "limit_exceeded").Inc()
+			return err
+		}
+
+		if account.Balance < amount {
+			metrics.FailedWithdrawals.WithLabelValues(account.AccountType, "insufficient_balance").Inc()
+			return InsufficientFundsError("insufficient_balance", "insufficient balance")
+		}
+
+		txRecord, err = postDebit(tx, account, "withdrawal", amount, description)
+		return err
 	})
 
 	if err != nil {
+		logging.FromContext(ctx).Warn("withdrawal failed", "account_id", accountID, "amount", amount, "error", err)
 		return nil, err
 	}
 
+	logging.FromContext(ctx).Info("withdrawal completed", "account_id", accountID, "transaction_id", txRecord.ID, "amount", amount)
+	metrics.Withdrawals.WithLabelValues(accountType).Inc()
+	metrics.WithdrawalAmount.WithLabelValues(accountType).Add(amount)
 	return txRecord, nil
 }
 
+// TransferResult holds both legs of a transfer between two accounts.
+type TransferResult struct {
+	Debit  models.Transaction `json:"debit"`
+	Credit models.Transaction `json:"credit"`
+}
+
+// Transfer moves amount between two accounts of the bank on the instruction of the given
+// customers, who have to satisfy the source account's operating mandate.
+func (s *AccountService) Transfer(ctx context.Context, fromID, toID uint, amount float64, description string, customerIDs []uint) (*TransferResult, error) {
+	if amount <= 0 {
+		return nil, ValidationError("invalid_amount", "amount must be greater than zero")
+	}
+	if fromID == toID {
+		return nil, ValidationError("same_account", "source and destination accounts must differ")
+	}
+
+	if s.approvals.requiresApproval(ctx, OpTransfer, amount) {
+		//reject what would fail anyway before bothering a checker
+		var from, to models.Account
+		if err := s.db.WithContext(ctx).First(&from, fromID).Error; err != nil {
+			return nil, dbError(err, "account")
+		}
+		if err := s.db.WithContext(ctx).First(&to, toID).Error; err != nil {
+			return nil, dbError(err, "account")
+		}
+		if err := ensureCanDebit(&from); err != nil {
+			return nil, err
+		}
+		if to.Status == models.AccountStatusFrozen || to.Status == models.AccountStatusClosed {
+			return nil, accountStatusError(&to)
+		}
+		if err := checkMandate(s.db.WithContext(ctx), &from, customerIDs); err != nil {
+			return nil, err
+		}
+		if err := checkLimits(s.db.WithContext(ctx), &from, amount, time.Now()); err != nil {
+			return nil, err
+		}
+		return nil, s.approvals.submit(ctx, OpTransfer, "account", fromID, amount, transferPayload{
+			FromAccountID: fromID,
+			ToAccountID:   toID,
+			Amount:        amount,
+			Description:   description,
+			CustomerIDs:   customerIDs,
+		})
+	}
+
+	var result *TransferResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.transfer(tx, fromID, toID, amount, description, customerIDs)
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("transfer failed", "from_account_id", fromID, "to_account_id", toID, "amount", amount, "error", err)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("transfer completed", "from_account_id", fromID, "to_account_id", toID, "debit_id", result.Debit.ID, "credit_id", result.Credit.ID, "amount", amount)
+	return result, nil
+}
+
+// transfer posts both legs inside the caller's transaction. The accounts are locked in id order
+// so two transfers going opposite ways can't deadlock.
+func (s *AccountService) transfer(tx *gorm.DB, fromID, toID uint, amount float64, description string, customerIDs []uint) (*TransferResult, error) {
+	first, second := fromID, toID
+	if second < first {
+		first, second = second, first
+	}
+	locked := map[uint]*models.Account{}
+	for _, id := range []uint{first, second} {
+		account, err := lockAccount(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		locked[id] = account
+	}
+	from, to := locked[fromID], locked[toID]
+
+	if err := ensureCanDebit(from); err != nil {
+		return nil, err
+	}
+	if err := checkMandate(tx, from, customerIDs); err != nil {
+		return nil, err
+	}
+	if err := checkLimits(tx, from, amount, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := ensureCanCredit(tx, to); err != nil {
+		return nil, err
+	}
+	if from.Balance < amount {
+		return nil, InsufficientFundsError("insufficient_balance", "insufficient balance")
+	}
+
+	if description == "" {
+		description = fmt.Sprintf("transfer from %s to %s", from.AccountNumber, to.AccountNumber)
+	}
+	debit, err := postDebit(tx, from, "transfer_out", amount, description)
+	if err != nil {
+		return nil, err
+	}
+	credit, err := postCredit(tx, to, "transfer_in", amount, description)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferResult{Debit: *debit, Credit: *credit}, nil
+}
+
+type transferPayload struct {
+	FromAccountID uint    `json:"from_account_id"`
+	ToAccountID   uint    `json:"to_account_id"`
+	Amount        float64 `json:"amount"`
+	Description   string  `json:"description"`
+	CustomerIDs   []uint  `json:"customer_ids"`
+}
+
+func (s *AccountService) executeTransfer(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p transferPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	return s.withDB(tx).Transfer(ctx, p.FromAccountID, p.ToAccountID, p.Amount, p.Description, p.CustomerIDs)
+}
+
+type withdrawalPayload struct {
+	AccountID   uint    `json:"account_id"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+	CustomerIDs []uint  `json:"customer_ids"`
+}
+
+func (s *AccountService) executeWithdrawal(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p withdrawalPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	return s.withDB(tx).Withdraw(ctx, p.AccountID, p.Amount, p.Description, p.CustomerIDs)
+}
+
+type removeHolderPayload struct {
+	AccountID  uint `json:"account_id"`
+	CustomerID uint `json:"customer_id"`
+}
+
+func (s *AccountService) executeRemoveHolder(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p removeHolderPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	accounts := s.withDB(tx)
+	if err := accounts.RemoveCustomer(ctx, p.AccountID, p.CustomerID); err != nil {
+		return nil, err
+	}
+	return accounts.GetAccountDetail(ctx, p.AccountID, false)
+}
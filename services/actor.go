@@ -0,0 +1,20 @@
+package services
+
+import "context"
+
+// Actor is the bank employee (or system process) on whose behalf a request runs.
+type Actor struct {
+	ID   string
+	Role string
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok && actor.ID != ""
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// operations that can be held for a second approval
+const (
+	OpWithdrawal   = "withdrawal"
+	OpLoanApproval = "loan_approval"
+	OpRemoveHolder = "remove_holder"
+	OpLimitChange  = "limit_change"
+	OpTransfer     = "transfer"
+	// standing instructions are approved once, their runs go through under that approval
+	OpStandingInstruction = "standing_instruction"
+)
+
+type ApprovalPolicy struct {
+	// Thresholds lists the gated operations, an operation needs approval when its amount is at
+	// or above the threshold
+	Thresholds map[string]float64
+	// ApproverRoles may approve or reject, the maker of a request never can
+	ApproverRoles []string
+}
+
+// Executor runs an approved operation from its stored payload inside the transaction recording
+// the approval, and returns what the operation would have returned to the maker.
+type Executor func(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error)
+
+// RejectionHook lets the resource an operation was parked on know the request was rejected, in
+// the same transaction as the decision.
+type RejectionHook func(ctx context.Context, tx *gorm.DB, request *models.ApprovalRequest) error
+
+// PendingApprovalError is returned instead of a result when an operation was parked for approval.
+type PendingApprovalError struct {
+	Request *models.ApprovalRequest
+}
+
+func (e *PendingApprovalError) Error() string {
+	return fmt.Sprintf("%s is waiting for approval (request %d)", e.Request.Operation, e.Request.ID)
+}
+
+type approvedKey struct{}
+
+type ApprovalService struct {
+	db         *gorm.DB
+	policy     ApprovalPolicy
+	executors  map[string]Executor
+	rejections map[string]RejectionHook
+}
+
+func NewApprovalService(db *gorm.DB, policy ApprovalPolicy) *ApprovalService {
+	return &ApprovalService{db: db, policy: policy, executors: map[string]Executor{}, rejections: map[string]RejectionHook{}}
+}
+
+// Register wires the executor the operation runs with once approved, services register their
+// own operations when they are constructed.
+func (s *ApprovalService) Register(operation string, executor Executor) {
+	s.executors[operation] = executor
+}
+
+// OnReject wires the hook run when a request for the operation is rejected.
+func (s *ApprovalService) OnReject(operation string, hook RejectionHook) {
+	s.rejections[operation] = hook
+}
+
+// requiresApproval is false for operations below their threshold and for the execution of an
+// already approved request.
+func (s *ApprovalService) requiresApproval(ctx context.Context, operation string, amount float64) bool {
+	if s == nil {
+		return false
+	}
+	if _, approved := ctx.Value(approvedKey{}).(uint); approved {
+		return false
+	}
+	threshold, gated := s.policy.Thresholds[operation]
+	return gated && amount >= threshold
+}
+
+// submit parks the operation and returns a *PendingApprovalError describing the stored request.
+func (s *ApprovalService) submit(ctx context.Context, operation, resourceType string, resourceID uint, amount float64, payload interface{}) error {
+	var request *models.ApprovalRequest
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = s.park(ctx, tx, operation, resourceType, resourceID, amount, payload)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return pending(ctx, request)
+}
+
+// park stores the request in the caller's transaction, for callers that move the resource into
+// a waiting state in the same transaction. They return pending(ctx, request) once it commits.
+func (s *ApprovalService) park(ctx context.Context, tx *gorm.DB, operation, resourceType string, resourceID uint, amount float64, payload interface{}) (*models.ApprovalRequest, error) {
+	maker, ok := ActorFromContext(ctx)
+	if !ok {
+		return nil, ForbiddenError("actor_required", "X-User-ID is required for operations that need approval")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	request := models.ApprovalRequest{
+		Operation:    operation,
+		Status:       models.ApprovalStatusPending,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Amount:       amount,
+		Payload:      body,
+		MakerID:      maker.ID,
+		MakerRole:    maker.Role,
+	}
+	if err := tx.Create(&request).Error; err != nil {
+		return nil, err
+	}
+	if err := recordApprovalEvent(tx, request.ID, "submitted", maker, ""); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// pending reports a committed request back to the maker.
+func pending(ctx context.Context, request *models.ApprovalRequest) error {
+	logging.FromContext(ctx).Info("operation waiting for approval", "approval_id", request.ID, "operation", request.Operation, "maker", request.MakerID)
+	return &PendingApprovalError{Request: request}
+}
+
+func (s *ApprovalService) GetAll(ctx context.Context, status string) ([]models.ApprovalRequest, error) {
+	query := s.db.WithContext(ctx).Order("id desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []models.ApprovalRequest
+	if err := query.Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s *ApprovalService) GetByID(ctx context.Context, id uint) (*models.ApprovalRequest, error) {
+	var request models.ApprovalRequest
+	if err := s.db.WithContext(ctx).Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).First(&request, id).Error; err != nil {
+		return nil, dbError(err, "approval")
+	}
+	return &request, nil
+}
+
+// Approve records the checker's decision and executes the operation in one transaction. A
+// failing execution (e.g. the balance dropped in the meantime) is rolled back on its own and
+// leaves the request failed with the reason.
+func (s *ApprovalService) Approve(ctx context.Context, id uint, note string) (*models.ApprovalRequest, error) {
+	checker, err := s.checker(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var request models.ApprovalRequest
+	status := models.ApprovalStatusExecuted
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := decide(tx, &request, id, checker, models.ApprovalStatusApproved, note); err != nil {
+			return err
+		}
+
+		action := "executed"
+		var result json.RawMessage
+		var failure string
+		executor, ok := s.executors[request.Operation]
+		if !ok {
+			status, action, failure = models.ApprovalStatusFailed, "failed", "no executor registered for "+request.Operation
+		} else {
+			var out interface{}
+			//the operation runs in a savepoint so a failure undoes only the operation
+			execErr := tx.Transaction(func(op *gorm.DB) error {
+				var err error
+				out, err = executor(context.WithValue(ctx, approvedKey{}, request.ID), op, request.Payload)
+				return err
+			})
+			if execErr != nil {
+				status, action, failure = models.ApprovalStatusFailed, "failed", truncate(execErr.Error(), 255)
+			} else if out != nil {
+				result, _ = json.Marshal(out)
+			}
+		}
+
+		updates := map[string]interface{}{"status": status, "failure_reason": failure, "version": gorm.Expr("version + 1")}
+		if result != nil {
+			updates["result"] = result
+		}
+		if err := tx.Model(&models.ApprovalRequest{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		return recordApprovalEvent(tx, id, action, checker, failure)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("approval decided", "approval_id", id, "operation", request.Operation, "status", status, "checker", checker.ID)
+	return s.GetByID(ctx, id)
+}
+
+// Reject records the decision and runs the operation's rejection hook in the same transaction.
+func (s *ApprovalService) Reject(ctx context.Context, id uint, note string) (*models.ApprovalRequest, error) {
+	checker, err := s.checker(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var request models.ApprovalRequest
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := decide(tx, &request, id, checker, models.ApprovalStatusRejected, note); err != nil {
+			return err
+		}
+		if hook, ok := s.rejections[request.Operation]; ok {
+			return hook(ctx, tx, &request)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("approval decided", "approval_id", id, "operation", request.Operation, "status", request.Status, "checker", checker.ID)
+	return s.GetByID(ctx, id)
+}
+
+func (s *ApprovalService) checker(ctx context.Context) (Actor, error) {
+	checker, ok := ActorFromContext(ctx)
+	if !ok {
+		return checker, ForbiddenError("actor_required", "X-User-ID is required to decide on approvals")
+	}
+	if !s.isApprover(checker) {
+		return checker, ForbiddenError("not_an_approver", "role "+checker.Role+" cannot decide on approvals")
+	}
+	return checker, nil
+}
+
+// decide moves a pending request to approved or rejected. The row lock makes sure two checkers
+// can't both act on the same request.
+func decide(tx *gorm.DB, request *models.ApprovalRequest, id uint, checker Actor, status, note string) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(request, id).Error; err != nil {
+		return dbError(err, "approval")
+	}
+	if request.Status != models.ApprovalStatusPending {
+		return ConflictError("approval_not_pending", "approval request is already "+request.Status)
+	}
+	if request.MakerID == checker.ID {
+		return ForbiddenError("maker_cannot_approve", "the maker of a request cannot decide on it")
+	}
+
+	now := time.Now()
+	request.Status = status
+	request.CheckerID = checker.ID
+	request.DecisionNote = note
+	request.DecidedAt = &now
+	if err := tx.Model(request).Updates(map[string]interface{}{
+		"status":        status,
+		"checker_id":    checker.ID,
+		"decision_note": note,
+		"decided_at":    now,
+		"version":       gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	return recordApprovalEvent(tx, id, status, checker, note)
+}
+
+func (s *ApprovalService) isApprover(actor Actor) bool {
+	for _, role := range s.policy.ApproverRoles {
+		if role == actor.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts s to at most n characters, varchar sizes count characters and cutting bytes
+// could split a multi-byte one.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+func recordApprovalEvent(tx *gorm.DB, requestID uint, action string, actor Actor, note string) error {
+	return tx.Create(&models.ApprovalEvent{
+		ApprovalRequestID: requestID,
+		Action:            action,
+		ActorID:           actor.ID,
+		ActorRole:         actor.Role,
+		Note:              note,
+	}).Error
+}
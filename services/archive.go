@@ -0,0 +1,42 @@
+package services
+
+import (
+	"gorm.io/gorm"
+)
+
+// scoped lets auditors see soft-deleted rows, everyone else gets GORM's default deleted_at filter.
+func scoped(db *gorm.DB, includeDeleted bool) *gorm.DB {
+	if includeDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+func checkVersion(current, expected uint, entity string) error {
+	if expected != 0 && current != expected {
+		return PreconditionFailedError(entity+"_version_mismatch", entity+" was modified by another request, fetch it again and retry")
+	}
+	return nil
+}
+
+// restoreDeleted clears deleted_at on a soft-deleted row.
+func restoreDeleted(db *gorm.DB, model interface{}, id uint, entity string) error {
+	result := db.Unscoped().Model(model).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return dbError(result.Error, entity)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := db.Unscoped().Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return NotFoundError(entity+"_not_found", entity+" not found")
+	}
+	return ConflictError(entity+"_not_deleted", entity+" is not deleted")
+}
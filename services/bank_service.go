@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+
 	"banking_system/models"
 
 	"gorm.io/gorm"
@@ -14,31 +16,60 @@ func NewBankService(db *gorm.DB) *BankService {
 	return &BankService{db: db}
 }
 
-func (s *BankService) Create(bank *models.Bank) error {
-	return s.db.Create(bank).Error
+func (s *BankService) Create(ctx context.Context, bank *models.Bank) error {
+	return dbError(s.db.WithContext(ctx).Create(bank).Error, "bank")
 }
 
-func (s *BankService) GetByID(id uint) (*models.Bank, error) {
+func (s *BankService) GetByID(ctx context.Context, id uint) (*models.Bank, error) {
 	var bank models.Bank
-	if err := s.db.First(&bank, id).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).First(&bank, id).Error; err != nil {
+		return nil, dbError(err, "bank")
 	}
 	return &bank, nil
 }
 
-func (s *BankService) GetAll() ([]models.Bank, error) {
+func (s *BankService) GetAll(ctx context.Context) ([]models.Bank, error) {
 	var banks []models.Bank
-	if err := s.db.Find(&banks).Error; err != nil {
+	if err := s.db.WithContext(ctx).Find(&banks).Error; err != nil {
 		return nil, err
 	}
 	return banks, nil
 }
 
-func (s *BankService) Update(bank *models.Bank) error {
-	return s.db.Save(bank).Error
+func (s *BankService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.Bank, error) {
+	if code, ok := changes["code"].(string); ok {
+		if err := s.ensureCodeChangeAllowed(ctx, id, code); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyChanges(s.db.WithContext(ctx), &models.Bank{}, id, version, changes, "bank"); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+// ensureCodeChangeAllowed keeps branch routing codes and account IBANs valid: once a bank has
+// branches only the location and branch parts of its BIC may change.
+func (s *BankService) ensureCodeChangeAllowed(ctx context.Context, id uint, code string) error {
+	bank, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(bank.Code) >= 6 && len(code) >= 6 && bank.Code[:6] == code[:6] {
+		return nil
+	}
+
+	var branches int64
+	if err := s.db.WithContext(ctx).Model(&models.Branch{}).Where("bank_id = ?", id).Count(&branches).Error; err != nil {
+		return err
+	}
+	if branches > 0 {
+		return ConflictError("bank_has_branches", "institution and country of bank code "+bank.Code+" cannot change while the bank has branches")
+	}
+	return nil
 }
 
-func (s *BankService) Delete(id uint) error {
-	return s.db.Delete(&models.Bank{}, id).Error
+func (s *BankService) Delete(ctx context.Context, id, version uint) error {
+	return deleteVersioned(s.db.WithContext(ctx), &models.Bank{}, id, version, "bank")
 }
 
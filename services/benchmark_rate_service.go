@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BenchmarkRateService struct {
+	db *gorm.DB
+}
+
+func NewBenchmarkRateService(db *gorm.DB) *BenchmarkRateService {
+	return &BenchmarkRateService{db: db}
+}
+
+func (s *BenchmarkRateService) Create(ctx context.Context, benchmark *models.BenchmarkRate) error {
+	if benchmark.EffectiveFrom.IsZero() {
+		benchmark.EffectiveFrom = time.Now()
+	}
+	return dbError(s.db.WithContext(ctx).Create(benchmark).Error, "benchmark_rate")
+}
+
+func (s *BenchmarkRateService) GetByID(ctx context.Context, id uint) (*models.BenchmarkRate, error) {
+	var benchmark models.BenchmarkRate
+	if err := s.db.WithContext(ctx).First(&benchmark, id).Error; err != nil {
+		return nil, dbError(err, "benchmark_rate")
+	}
+	return &benchmark, nil
+}
+
+func (s *BenchmarkRateService) GetAll(ctx context.Context) ([]models.BenchmarkRate, error) {
+	var benchmarks []models.BenchmarkRate
+	if err := s.db.WithContext(ctx).Order("id asc").Find(&benchmarks).Error; err != nil {
+		return nil, err
+	}
+	return benchmarks, nil
+}
+
+// Update changes the benchmark and, when the rate moved, reprices every ongoing floating loan
+// linked to it in the same transaction.
+func (s *BenchmarkRateService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.BenchmarkRate, error) {
+	rate, rateChanged := changes["rate"].(float64)
+	if rateChanged {
+		changes["effective_from"] = time.Now()
+	}
+
+	var repriced int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := applyChanges(tx, &models.BenchmarkRate{}, id, version, changes, "benchmark_rate"); err != nil {
+			return err
+		}
+		if !rateChanged {
+			return nil
+		}
+		var loans []models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("benchmark_id = ? AND rate_type = ? AND status <> ?", id, models.RateTypeFloating, "closed").
+			Find(&loans).Error; err != nil {
+			return err
+		}
+		//repricing keeps the tenure and changes the installments still to come
+		for i := range loans {
+			loan := &loans[i]
+			loan.InterestRate = rate + loan.Spread
+			if err := rescheduleUnpaid(tx, loan, 0, RescheduleReduceEMI, "benchmark repricing"); err != nil {
+				return err
+			}
+			if err := tx.Model(loan).Updates(map[string]interface{}{
+				"loan_interest":  loan.InterestRate,
+				"terms_revision": loan.TermsRevision,
+				"version":        gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		repriced = len(loans)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rateChanged {
+		logging.FromContext(ctx).Info("benchmark rate changed", "benchmark_id", id, "rate", rate, "loans_repriced", repriced)
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *BenchmarkRateService) Delete(ctx context.Context, id, version uint) error {
+	return deleteVersioned(s.db.WithContext(ctx), &models.BenchmarkRate{}, id, version, "benchmark_rate")
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+
+	"banking_system/identifiers"
 	"banking_system/models"
 
 	"gorm.io/gorm"
@@ -14,31 +17,88 @@ func NewBranchService(db *gorm.DB) *BranchService {
 	return &BranchService{db: db}
 }
 
-func (s *BranchService) Create(branch *models.Branch) error {
-	return s.db.Create(branch).Error
+func (s *BranchService) Create(ctx context.Context, branch *models.Branch) error {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Bank{}, branch.BankID, "bank_id", "bank"); err != nil {
+		return err
+	}
+	if err := s.ensureRoutingCode(ctx, branch.Code, branch.BankID); err != nil {
+		return err
+	}
+	return dbError(s.db.WithContext(ctx).Create(branch).Error, "branch")
 }
 
-func (s *BranchService) GetByID(id uint) (*models.Branch, error) {
+func (s *BranchService) GetByID(ctx context.Context, id uint) (*models.Branch, error) {
 	var branch models.Branch
-	if err := s.db.First(&branch, id).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).First(&branch, id).Error; err != nil {
+		return nil, dbError(err, "branch")
 	}
 	return &branch, nil
 }
 
-func (s *BranchService) GetAll() ([]models.Branch, error) {
+func (s *BranchService) GetAll(ctx context.Context) ([]models.Branch, error) {
 	var branches []models.Branch
-	if err := s.db.Find(&branches).Error; err != nil {
+	if err := s.db.WithContext(ctx).Find(&branches).Error; err != nil {
 		return nil, err
 	}
 	return branches, nil
 }
 
-func (s *BranchService) Update(branch *models.Branch) error {
-	return s.db.Save(branch).Error
+func (s *BranchService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.Branch, error) {
+	refID, bankChanged := changes["bank_id"].(uint)
+	if bankChanged {
+		if err := ensureExists(s.db.WithContext(ctx), &models.Bank{}, refID, "bank_id", "bank"); err != nil {
+			return nil, err
+		}
+	}
+	code, codeChanged := changes["code"].(string)
+	if bankChanged || codeChanged {
+		current, err := s.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !bankChanged {
+			refID = current.BankID
+		}
+		if !codeChanged {
+			code = current.Code
+		}
+		if err := s.ensureRoutingCode(ctx, code, refID); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyChanges(s.db.WithContext(ctx), &models.Branch{}, id, version, changes, "branch"); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+// GetByRoutingCode resolves a routing code to its branch and the bank it belongs to.
+func (s *BranchService) GetByRoutingCode(ctx context.Context, code string) (*models.BranchRouting, error) {
+	code = identifiers.Normalize(code)
+	if !identifiers.ValidRoutingCode(code) {
+		return nil, ValidationError("invalid_routing_code", "routing code must be a 4 letter bank code, 0 and 6 letters or digits")
+	}
+
+	var branch models.Branch
+	if err := s.db.WithContext(ctx).Preload("Bank").Where("code = ?", code).First(&branch).Error; err != nil {
+		return nil, dbError(err, "branch")
+	}
+	return &models.BranchRouting{Branch: branch, Bank: branch.Bank}, nil
+}
+
+// ensureRoutingCode checks that a branch routing code starts with its bank's institution code.
+func (s *BranchService) ensureRoutingCode(ctx context.Context, code string, bankID uint) error {
+	var bank models.Bank
+	if err := s.db.WithContext(ctx).First(&bank, bankID).Error; err != nil {
+		return dbError(err, "bank")
+	}
+	if !identifiers.RoutingCodeMatchesBank(code, bank.Code) {
+		return FieldValidationError(FieldError{Field: "code", Message: "must start with the bank code " + identifiers.InstitutionCode(bank.Code) + " followed by 0"})
+	}
+	return nil
 }
 
-func (s *BranchService) Delete(id uint) error {
-	return s.db.Delete(&models.Branch{}, id).Error
+func (s *BranchService) Delete(ctx context.Context, id, version uint) error {
+	return deleteVersioned(s.db.WithContext(ctx), &models.Branch{}, id, version, "branch")
 }
 
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollateralService struct {
+	db *gorm.DB
+}
+
+func NewCollateralService(db *gorm.DB) *CollateralService {
+	return &CollateralService{db: db}
+}
+
+// Pledge offers collateral on an application that is still waiting for a decision.
+func (s *CollateralService) Pledge(ctx context.Context, applicationID uint, collateral *models.Collateral) error {
+	db := s.db.WithContext(ctx)
+	if _, err := undecidedApplication(db, applicationID); err != nil {
+		return err
+	}
+	if err := checkValuationDate(collateral); err != nil {
+		return err
+	}
+	collateral.ApplicationID = applicationID
+	collateral.LoanID = nil
+	collateral.LienStatus = models.LienPending
+	return dbError(db.Create(collateral).Error, "collateral")
+}
+
+// AddToLoan secures an ongoing loan with further collateral, the lien is marked straight away.
+func (s *CollateralService) AddToLoan(ctx context.Context, loanID uint, collateral *models.Collateral) error {
+	db := s.db.WithContext(ctx)
+	loan, err := ongoingLoan(db, loanID)
+	if err != nil {
+		return err
+	}
+	if err := checkValuationDate(collateral); err != nil {
+		return err
+	}
+	//loans booked before applications existed have nothing to hang the collateral on
+	if loan.ApplicationID == nil {
+		return ValidationError("loan_without_application", fmt.Sprintf("loan %d was not booked from an application", loan.ID))
+	}
+	collateral.ApplicationID = *loan.ApplicationID
+	collateral.LoanID = &loan.ID
+	collateral.LienStatus = models.LienMarked
+	return dbError(db.Create(collateral).Error, "collateral")
+}
+
+func (s *CollateralService) GetForApplication(ctx context.Context, applicationID uint) ([]models.Collateral, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.LoanApplication{}, applicationID, "application_id", "loan application"); err != nil {
+		return nil, err
+	}
+	var collateral []models.Collateral
+	if err := s.db.WithContext(ctx).Where("application_id = ?", applicationID).Order("id asc").Find(&collateral).Error; err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+func (s *CollateralService) GetForLoan(ctx context.Context, loanID uint) ([]models.Collateral, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Loan{}, loanID, "loan_id", "loan"); err != nil {
+		return nil, err
+	}
+	var collateral []models.Collateral
+	if err := s.db.WithContext(ctx).Where("loan_id = ?", loanID).Order("id asc").Find(&collateral).Error; err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+func (s *CollateralService) getForLoan(db *gorm.DB, loanID, id uint) (*models.Collateral, error) {
+	var collateral models.Collateral
+	if err := db.Where("loan_id = ?", loanID).First(&collateral, id).Error; err != nil {
+		return nil, dbError(err, "collateral")
+	}
+	return &collateral, nil
+}
+
+// Revalue records a new valuation of collateral held against the loan.
+func (s *CollateralService) Revalue(ctx context.Context, loanID, id, version uint, changes map[string]interface{}) (*models.Collateral, error) {
+	var collateral *models.Collateral
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := s.getForLoan(tx, loanID, id); err != nil {
+			return err
+		}
+		if err := applyChanges(tx, &models.Collateral{}, id, version, changes, "collateral"); err != nil {
+			return err
+		}
+		var err error
+		collateral, err = s.getForLoan(tx, loanID, id)
+		if err != nil {
+			return err
+		}
+		return checkValuationDate(collateral)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+// Release lifts the lien. Collateral of an ongoing loan is only released when what remains still
+// covers the outstanding principal within the product's loan-to-value.
+func (s *CollateralService) Release(ctx context.Context, loanID, id uint) (*models.Collateral, error) {
+	var collateral *models.Collateral
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, loanID).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		var err error
+		collateral, err = s.getForLoan(tx, loanID, id)
+		if err != nil {
+			return err
+		}
+		if collateral.LienStatus == models.LienReleased {
+			return ConflictError("lien_already_released", "lien on the collateral is already released")
+		}
+
+		if loan.Status != "closed" {
+			var product models.LoanProduct
+			if err := tx.First(&product, loan.ProductID).Error; err != nil {
+				return dbError(err, "loan_product")
+			}
+			if product.MaxLTVPercent > 0 {
+				outstanding, err := principalOutstanding(tx, loan.ID)
+				if err != nil {
+					return err
+				}
+				var remaining float64
+				if err := tx.Model(&models.Collateral{}).
+					Where("loan_id = ? AND lien_status = ? AND id <> ?", loan.ID, models.LienMarked, id).
+					Select("COALESCE(SUM(valuation), 0)").Scan(&remaining).Error; err != nil {
+					return err
+				}
+				if remaining <= 0 || outstanding/remaining*100 > product.MaxLTVPercent {
+					return ConflictError("loan_to_value_exceeded", fmt.Sprintf("releasing the collateral would leave %.2f outstanding against %.2f of collateral, above the %.2f%% loan-to-value of %s", outstanding, remaining, product.MaxLTVPercent, product.Code))
+				}
+			}
+		}
+
+		collateral.LienStatus = models.LienReleased
+		collateral.Version++
+		return tx.Model(collateral).Updates(map[string]interface{}{"lien_status": collateral.LienStatus, "version": collateral.Version}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+func checkValuationDate(collateral *models.Collateral) error {
+	if collateral.ValuationDate.IsZero() {
+		collateral.ValuationDate = time.Now()
+	}
+	if collateral.ValuationDate.After(time.Now()) {
+		return FieldValidationError(FieldError{Field: "valuation_date", Message: "must not be in the future"})
+	}
+	return nil
+}
+
+// undecidedApplication loads an application security can still be offered on.
+func undecidedApplication(db *gorm.DB, id uint) (*models.LoanApplication, error) {
+	var application models.LoanApplication
+	if err := db.First(&application, id).Error; err != nil {
+		return nil, dbError(err, "loan_application")
+	}
+	if application.Status != models.LoanApplicationSubmitted && application.Status != models.LoanApplicationAwaitingApproval {
+		return nil, ConflictError("loan_application_not_submitted", "loan application is already "+application.Status)
+	}
+	return &application, nil
+}
+
+func ongoingLoan(db *gorm.DB, id uint) (*models.Loan, error) {
+	var loan models.Loan
+	if err := db.First(&loan, id).Error; err != nil {
+		return nil, dbError(err, "loan")
+	}
+	if loan.Status == "closed" {
+		return nil, ConflictError("loan_closed", "loan is already closed")
+	}
+	return &loan, nil
+}
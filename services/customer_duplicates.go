@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"banking_system/models"
+)
+
+// how much each signal adds to a pair's duplicate score, a known date of birth that differs
+// counts against the pair
+const (
+	duplicateNameWeight   = 0.5
+	duplicatePhoneWeight  = 0.3
+	duplicateBirthWeight  = 0.2
+	duplicateBirthPenalty = 0.3
+	duplicateSimilarName  = 0.9
+)
+
+// DefaultDuplicateMinScore is the lowest score FindDuplicates reports unless told otherwise.
+const DefaultDuplicateMinScore = 0.7
+
+type DuplicateFilter struct {
+	// CustomerID limits the result to pairs involving this customer, 0 for every pair
+	CustomerID uint
+	// MinScore is the lowest score reported, 0 for DefaultDuplicateMinScore
+	MinScore float64
+}
+
+// DuplicateMatch is a pair of customers that look like the same person. Customer is the one
+// created first, which is usually the one to keep.
+type DuplicateMatch struct {
+	Customer  models.Customer `json:"customer"`
+	Duplicate models.Customer `json:"duplicate"`
+	Score     float64         `json:"score"`
+	Reasons   []string        `json:"reasons"`
+}
+
+// FindDuplicates scores pairs of customers on name similarity, phone number and date of birth.
+// Only customers sharing a phone number, a date of birth or a name that sounds alike are compared.
+func (s *CustomerService) FindDuplicates(ctx context.Context, filter DuplicateFilter) ([]DuplicateMatch, error) {
+	minScore := filter.MinScore
+	if minScore == 0 {
+		minScore = DefaultDuplicateMinScore
+	}
+	if filter.CustomerID != 0 {
+		if _, err := s.GetByID(ctx, filter.CustomerID, false); err != nil {
+			return nil, err
+		}
+	}
+
+	var customers []models.Customer
+	if err := s.db.WithContext(ctx).Order("id asc").Find(&customers).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := map[string][]int{}
+	for i, customer := range customers {
+		for _, key := range blockingKeys(&customer) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+
+	matches := []DuplicateMatch{}
+	seen := map[[2]int]bool{}
+	for _, members := range blocks {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				pair := [2]int{members[x], members[y]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				a, b := &customers[pair[0]], &customers[pair[1]]
+				if filter.CustomerID != 0 && a.ID != filter.CustomerID && b.ID != filter.CustomerID {
+					continue
+				}
+				score, reasons := duplicateScore(a, b)
+				if score < minScore {
+					continue
+				}
+				matches = append(matches, DuplicateMatch{Customer: *a, Duplicate: *b, Score: score, Reasons: reasons})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Customer.ID != matches[j].Customer.ID {
+			return matches[i].Customer.ID < matches[j].Customer.ID
+		}
+		return matches[i].Duplicate.ID < matches[j].Duplicate.ID
+	})
+	return matches, nil
+}
+
+func blockingKeys(customer *models.Customer) []string {
+	var keys []string
+	if phone := normalizePhone(customer.Phone); phone != "" {
+		keys = append(keys, "phone:"+phone)
+	}
+	if customer.DateOfBirth != nil {
+		keys = append(keys, "dob:"+customer.DateOfBirth.Format("2006-01-02"))
+	}
+	//first and last names share a namespace so swapped names still meet
+	for _, name := range []string{customer.FirstName, customer.LastName} {
+		if code := soundex(normalizeName(name)); code != "" {
+			keys = append(keys, "name:"+code)
+		}
+	}
+	return keys
+}
+
+func duplicateScore(a, b *models.Customer) (float64, []string) {
+	reasons := []string{}
+	name := nameSimilarity(a, b)
+	score := duplicateNameWeight * name
+	if name >= duplicateSimilarName {
+		reasons = append(reasons, "similar_name")
+	}
+
+	if phone := normalizePhone(a.Phone); phone != "" && phone == normalizePhone(b.Phone) {
+		score += duplicatePhoneWeight
+		reasons = append(reasons, "same_phone")
+	}
+
+	if a.DateOfBirth != nil && b.DateOfBirth != nil {
+		if a.DateOfBirth.Format("2006-01-02") == b.DateOfBirth.Format("2006-01-02") {
+			score += duplicateBirthWeight
+			reasons = append(reasons, "same_date_of_birth")
+		} else {
+			score -= duplicateBirthPenalty
+		}
+	}
+	return math.Round(score*100) / 100, reasons
+}
+
+// nameSimilarity compares first with first and last with last, or crosswise when that fits
+// better, to catch names entered the wrong way round.
+func nameSimilarity(a, b *models.Customer) float64 {
+	firstA, lastA := normalizeName(a.FirstName), normalizeName(a.LastName)
+	firstB, lastB := normalizeName(b.FirstName), normalizeName(b.LastName)
+	direct := (jaroWinkler(firstA, firstB) + jaroWinkler(lastA, lastB)) / 2
+	swapped := (jaroWinkler(firstA, lastB) + jaroWinkler(lastA, firstB)) / 2
+	return math.Max(direct, swapped)
+}
+
+// normalizeName lowercases the name and drops everything but letters and single spaces.
+func normalizeName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// normalizePhone keeps the last ten digits, which drops country codes and trunk prefixes so
+// +15551234567 and 05551234567 compare equal. Numbers too short to be real give "".
+func normalizePhone(phone string) string {
+	var digits []byte
+	for i := 0; i < len(phone); i++ {
+		if phone[i] >= '0' && phone[i] <= '9' {
+			digits = append(digits, phone[i])
+		}
+	}
+	number := strings.TrimLeft(string(digits), "0")
+	if len(number) > 10 {
+		number = number[len(number)-10:]
+	}
+	if len(number) < 7 {
+		return ""
+	}
+	return number
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b, 1 for equal strings and 0 for
+// strings with nothing in common.
+func jaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[k] {
+			k++
+		}
+		if ra[i] != rb[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// soundex codes a name by how it sounds in English, names that aren't written in latin letters
+// give "".
+func soundex(name string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+	var code []byte
+	var last byte
+	for _, r := range name {
+		if r < 'a' || r > 'z' {
+			continue
+		}
+		digit := codes[r]
+		if len(code) == 0 {
+			code = append(code, byte(unicode.ToUpper(r)))
+			last = digit
+			continue
+		}
+		//h and w don't separate letters with the same code, vowels do
+		if r == 'h' || r == 'w' {
+			continue
+		}
+		if digit != 0 && digit != last {
+			code = append(code, digit)
+		}
+		last = digit
+		if len(code) == 4 {
+			break
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// holderRank orders roles by how much they allow, when both customers are on an account the
+// survivor keeps the stronger of the two
+var holderRank = map[string]int{
+	models.HolderRolePrimary:             5,
+	models.HolderRoleJoint:               4,
+	models.HolderRoleGuardian:            3,
+	models.HolderRoleAuthorizedSignatory: 2,
+	models.HolderRoleNominee:             1,
+}
+
+// Merge folds the duplicate into the survivor in one transaction: account links, loans, loan
+// applications, guarantees, KYC records and standing instructions given by the duplicate move
+// to the survivor, the duplicate is archived and the merge is recorded. A verified survivor that
+// takes over any of the duplicate's identity details goes back to pending KYC.
+func (s *CustomerService) Merge(ctx context.Context, survivorID, duplicateID uint, reason string) (*models.CustomerMerge, error) {
+	actor, err := s.merger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if survivorID == duplicateID {
+		return nil, ValidationError("merge_same_customer", "a customer cannot be merged into itself")
+	}
+
+	merge := models.CustomerMerge{SurvivorID: survivorID, DuplicateID: duplicateID, Reason: reason, MergedBy: actor.ID}
+	kycReset := false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		//lock in id order so two merges of the same pair can't deadlock
+		var customers []models.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{survivorID, duplicateID}).Order("id asc").Find(&customers).Error; err != nil {
+			return err
+		}
+		var survivor, duplicate *models.Customer
+		for i := range customers {
+			if customers[i].ID == survivorID {
+				survivor = &customers[i]
+			} else {
+				duplicate = &customers[i]
+			}
+		}
+		if survivor == nil || duplicate == nil {
+			return NotFoundError("customer_not_found", "customer not found")
+		}
+
+		if err := checkMergeGuarantees(tx, survivorID, duplicateID); err != nil {
+			return err
+		}
+
+		var err error
+		if merge.AccountLinks, err = mergeAccountLinks(tx, survivorID, duplicateID); err != nil {
+			return err
+		}
+		if merge.Loans, err = repoint(tx.Unscoped(), &models.Loan{}, survivorID, duplicateID, true); err != nil {
+			return err
+		}
+		if merge.LoanApplications, err = repoint(tx, &models.LoanApplication{}, survivorID, duplicateID, true); err != nil {
+			return err
+		}
+		if merge.Guarantees, err = repoint(tx, &models.LoanGuarantor{}, survivorID, duplicateID, true); err != nil {
+			return err
+		}
+		var kycRecords int64
+		for _, model := range []interface{}{&models.CustomerAddress{}, &models.CustomerIdentity{}, &models.CustomerDocument{}} {
+			moved, err := repoint(tx, model, survivorID, duplicateID, false)
+			if err != nil {
+				return err
+			}
+			kycRecords += moved
+		}
+		if merge.StandingInstructions, err = mergeInstructionCustomers(tx, survivorID, duplicateID); err != nil {
+			return err
+		}
+
+		changes := map[string]interface{}{"version": survivor.Version + 1}
+		if survivor.DateOfBirth == nil && duplicate.DateOfBirth != nil {
+			changes["date_of_birth"] = duplicate.DateOfBirth
+		}
+		//the verification didn't cover what came over from the duplicate
+		if survivor.KYCStatus == models.KYCVerified && (kycRecords > 0 || changes["date_of_birth"] != nil) {
+			kycReset = true
+			changes["kyc_status"] = models.KYCPending
+			changes["kyc_verified_at"] = nil
+			changes["kyc_expires_at"] = nil
+			changes["kyc_reviewed_by"] = ""
+			changes["kyc_note"] = fmt.Sprintf("merged with customer %d", duplicateID)
+		}
+		if err := tx.Model(survivor).Updates(changes).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(duplicate).Error; err != nil {
+			return err
+		}
+		return dbError(tx.Create(&merge).Error, "customer_merge")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("customers merged", "survivor_id", survivorID, "duplicate_id", duplicateID,
+		"account_links", merge.AccountLinks, "loans", merge.Loans, "kyc_reset", kycReset, "by", actor.ID)
+	return &merge, nil
+}
+
+func (s *CustomerService) merger(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return actor, ForbiddenError("actor_required", "X-User-ID is required to merge customers")
+	}
+	for _, role := range s.mergeRoles {
+		if role == actor.Role {
+			return actor, nil
+		}
+	}
+	return actor, ForbiddenError("not_a_merge_officer", "role "+actor.Role+" cannot merge customers")
+}
+
+// checkMergeGuarantees refuses merges that would leave the survivor guaranteeing their own loan
+// or guaranteeing the same application twice.
+func checkMergeGuarantees(tx *gorm.DB, survivorID, duplicateID uint) error {
+	var count int64
+	if err := tx.Model(&models.LoanGuarantor{}).
+		Joins("JOIN loan_applications ON loan_applications.id = loan_guarantors.application_id").
+		Where("loan_guarantors.customer_id IN ? AND loan_applications.customer_id IN ?", []uint{survivorID, duplicateID}, []uint{survivorID, duplicateID}).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ConflictError("merge_guarantees_own_loan", "one customer guarantees the other's loan, release the guarantee before merging")
+	}
+
+	if err := tx.Model(&models.LoanGuarantor{}).
+		Where("customer_id = ? AND application_id IN (?)", survivorID,
+			tx.Model(&models.LoanGuarantor{}).Select("application_id").Where("customer_id = ?", duplicateID)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ConflictError("merge_duplicate_guarantee", "both customers guarantee the same loan application")
+	}
+	return nil
+}
+
+// mergeAccountLinks moves the duplicate's account links over. Where both are on the same account
+// the duplicate's link is dropped and the survivor keeps the stronger role.
+func mergeAccountLinks(tx *gorm.DB, survivorID, duplicateID uint) (int64, error) {
+	var links []models.AccountCustomer
+	if err := tx.Where("customer_id = ?", duplicateID).Order("account_id asc").Find(&links).Error; err != nil {
+		return 0, err
+	}
+
+	for _, link := range links {
+		account, err := lockAccount(tx, link.AccountID)
+		if err != nil {
+			return 0, err
+		}
+
+		var existing []models.AccountCustomer
+		if err := tx.Where("account_id = ? AND customer_id = ?", link.AccountID, survivorID).Find(&existing).Error; err != nil {
+			return 0, err
+		}
+		if len(existing) == 0 {
+			if err := tx.Model(&link).Update("customer_id", survivorID).Error; err != nil {
+				return 0, fmt.Errorf("failed to move account link: %w", err)
+			}
+			continue
+		}
+
+		if err := tx.Delete(&link).Error; err != nil {
+			return 0, fmt.Errorf("failed to drop account link: %w", err)
+		}
+		if holderRank[link.Role] > holderRank[existing[0].Role] {
+			if err := tx.Model(&existing[0]).Update("role", link.Role).Error; err != nil {
+				return 0, err
+			}
+		}
+		//the account may have lost a holder
+		if err := syncJoint(tx, account); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(links)), nil
+}
+
+// repoint moves rows of model from the duplicate to the survivor, bumping their version when
+// they have one.
+func repoint(tx *gorm.DB, model interface{}, survivorID, duplicateID uint, versioned bool) (int64, error) {
+	changes := map[string]interface{}{"customer_id": survivorID}
+	if versioned {
+		changes["version"] = gorm.Expr("version + 1")
+	}
+	result := tx.Model(model).Where("customer_id = ?", duplicateID).Updates(changes)
+	return result.RowsAffected, result.Error
+}
+
+// mergeInstructionCustomers swaps the duplicate for the survivor in the customers that gave a
+// standing instruction, so the mandate check keeps passing after the merge.
+func mergeInstructionCustomers(tx *gorm.DB, survivorID, duplicateID uint) (int64, error) {
+	var instructions []models.StandingInstruction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_ids @> ?::jsonb", fmt.Sprintf("[%d]", duplicateID)).Find(&instructions).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range instructions {
+		instruction := &instructions[i]
+		customerIDs := make([]uint, 0, len(instruction.CustomerIDs))
+		seen := map[uint]bool{}
+		for _, id := range instruction.CustomerIDs {
+			if id == duplicateID {
+				id = survivorID
+			}
+			if !seen[id] {
+				seen[id] = true
+				customerIDs = append(customerIDs, id)
+			}
+		}
+		instruction.CustomerIDs = customerIDs
+		instruction.Version++
+		if err := tx.Model(instruction).Select("customer_ids", "version").Updates(instruction).Error; err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(instructions)), nil
+}
+
+func (s *CustomerService) GetMerges(ctx context.Context, customerID uint) ([]models.CustomerMerge, error) {
+	db := s.db.WithContext(ctx)
+	//merged-away customers are archived but their history is still wanted
+	if err := ensureExists(db.Unscoped(), &models.Customer{}, customerID, "customer_id", "customer"); err != nil {
+		return nil, err
+	}
+	var merges []models.CustomerMerge
+	if err := db.Where("survivor_id = ? OR duplicate_id = ?", customerID, customerID).Order("id asc").Find(&merges).Error; err != nil {
+		return nil, err
+	}
+	return merges, nil
+}
@@ -1,50 +1,128 @@
 package services
 
 import (
+	"context"
+	"time"
+
+	"banking_system/logging"
 	"banking_system/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CustomerService struct {
 	db *gorm.DB
+	// mergeRoles may merge duplicate customers
+	mergeRoles []string
 }
 
-func NewCustomerService(db *gorm.DB) *CustomerService {
-	return &CustomerService{db: db}
+func NewCustomerService(db *gorm.DB, mergeRoles []string) *CustomerService {
+	return &CustomerService{db: db, mergeRoles: mergeRoles}
 }
 
-func (s *CustomerService) Create(customer *models.Customer) error {
-	return s.db.Create(customer).Error
+func (s *CustomerService) Create(ctx context.Context, customer *models.Customer) error {
+	return dbError(s.db.WithContext(ctx).Create(customer).Error, "customer")
 }
 
-func (s *CustomerService) GetByID(id uint) (*models.Customer, error) {
+func (s *CustomerService) GetByID(ctx context.Context, id uint, includeDeleted bool) (*models.Customer, error) {
 	var customer models.Customer
-	if err := s.db.First(&customer, id).Error; err != nil {
-		return nil, err
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).First(&customer, id).Error; err != nil {
+		return nil, dbError(err, "customer")
 	}
 	return &customer, nil
 }
 
-func (s *CustomerService) GetAll() ([]models.Customer, error) {
+func (s *CustomerService) GetAll(ctx context.Context, includeDeleted bool) ([]models.Customer, error) {
 	var customers []models.Customer
-	if err := s.db.Find(&customers).Error; err != nil {
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).Find(&customers).Error; err != nil {
 		return nil, err
 	}
 	return customers, nil
 }
 
-func (s *CustomerService) Update(customer *models.Customer) error {
-	return s.db.Save(customer).Error
+// Update applies a partial update. Changing the name or date of birth of a verified customer sends
+// their KYC back to pending, the verification was of the old identity.
+func (s *CustomerService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.Customer, error) {
+	reset := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var customer models.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&customer, id).Error; err != nil {
+			return dbError(err, "customer")
+		}
+		if customer.KYCStatus == models.KYCVerified && identityChanged(&customer, changes) {
+			reset = true
+			changes["kyc_status"] = models.KYCPending
+			changes["kyc_verified_at"] = nil
+			changes["kyc_expires_at"] = nil
+			changes["kyc_reviewed_by"] = ""
+			changes["kyc_note"] = "identity details changed"
+		}
+		return applyChanges(tx, &models.Customer{}, id, version, changes, "customer")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reset {
+		logging.FromContext(ctx).Info("customer kyc reset after identity change", "customer_id", id)
+	}
+	return s.GetByID(ctx, id, false)
+}
+
+// identityChanged is true when the changes give the customer a different name or date of birth.
+func identityChanged(customer *models.Customer, changes map[string]interface{}) bool {
+	if name, ok := changes["first_name"].(string); ok && name != customer.FirstName {
+		return true
+	}
+	if name, ok := changes["last_name"].(string); ok && name != customer.LastName {
+		return true
+	}
+	if birth, ok := changes["date_of_birth"].(time.Time); ok {
+		return customer.DateOfBirth == nil || birth.Format("2006-01-02") != customer.DateOfBirth.Format("2006-01-02")
+	}
+	return false
+}
+
+// Delete archives the customer. Account links are kept so joint accounts still show the holder.
+func (s *CustomerService) Delete(ctx context.Context, id, version uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var customer models.Customer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&customer, id).Error; err != nil {
+			return dbError(err, "customer")
+		}
+		if err := checkVersion(customer.Version, version, "customer"); err != nil {
+			return err
+		}
+
+		var openLoans int64
+		if err := tx.Model(&models.Loan{}).Where("customer_id = ? AND status <> ?", id, "closed").Count(&openLoans).Error; err != nil {
+			return err
+		}
+		if openLoans > 0 {
+			return ConflictError("customer_has_open_loans", "customer has open loans and cannot be closed")
+		}
+		return dbError(tx.Delete(&customer).Error, "customer")
+	})
+}
+
+func (s *CustomerService) Restore(ctx context.Context, id uint) (*models.Customer, error) {
+	if err := restoreDeleted(s.db.WithContext(ctx), &models.Customer{}, id, "customer"); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id, false)
 }
 
-func (s *CustomerService) Delete(id uint) error {
-	return s.db.Delete(&models.Customer{}, id).Error
+// ExpireKYC moves verifications that ran out by now to expired and returns how many it changed.
+func (s *CustomerService) ExpireKYC(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.Customer{}).
+		Where("kyc_status = ? AND kyc_expires_at <= ?", models.KYCVerified, now).
+		Updates(map[string]interface{}{"kyc_status": models.KYCExpired, "version": gorm.Expr("version + 1")})
+	return result.RowsAffected, result.Error
 }
 
-func (s *CustomerService) GetAccounts(customerID uint) ([]models.Account, error) {
+func (s *CustomerService) GetAccounts(ctx context.Context, customerID uint) ([]models.Account, error) {
 	var accounts []models.Account
-	err := s.db.Table("accounts").
+	err := s.db.WithContext(ctx).Table("accounts").
 		Joins("JOIN account_customers ON account_customers.account_id = accounts.id").
 		Where("account_customers.customer_id = ?", customerID).
 		Find(&accounts).Error
@@ -54,11 +132,35 @@ func (s *CustomerService) GetAccounts(customerID uint) ([]models.Account, error)
 	return accounts, nil
 }
 
-func (s *CustomerService) GetLoans(customerID uint) ([]models.Loan, error) {
+func (s *CustomerService) GetLoans(ctx context.Context, customerID uint) ([]models.Loan, error) {
 	var loans []models.Loan
-	if err := s.db.Where("customer_id = ?", customerID).Find(&loans).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).Find(&loans).Error; err != nil {
 		return nil, err
 	}
 	return loans, nil
 }
 
+type CustomerLoans struct {
+	Loans             []models.Loan          `json:"loans"`
+	Guarantees        []models.LoanGuarantor `json:"guarantees"`
+	GuaranteeExposure float64                `json:"guarantee_exposure"`
+}
+
+// GetLoansAndGuarantees lists the customer's own loans next to the loans they guarantee and
+// what they stand to owe on them.
+func (s *CustomerService) GetLoansAndGuarantees(ctx context.Context, customerID uint) (*CustomerLoans, error) {
+	db := s.db.WithContext(ctx)
+	if err := ensureExists(db, &models.Customer{}, customerID, "customer_id", "customer"); err != nil {
+		return nil, err
+	}
+	loans, err := s.GetLoans(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	guaranteed, exposure, err := guarantees(db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomerLoans{Loans: loans, Guarantees: guaranteed, GuaranteeExposure: exposure}, nil
+}
+
@@ -0,0 +1,509 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// compounding periods per year
+var compoundingPeriods = map[string]int{
+	models.CompoundMonthly:    12,
+	models.CompoundQuarterly:  4,
+	models.CompoundHalfYearly: 2,
+	models.CompoundYearly:     1,
+}
+
+// compound grows amount at an annual percentage rate, compounded per the frequency, for months.
+func compound(amount, annualRate float64, compounding string, months int) float64 {
+	n := float64(compoundingPeriods[compounding])
+	return amount * math.Pow(1+annualRate/100/n, n*float64(months)/12)
+}
+
+// recurringMaturity is what the installments grow to by maturity, installment k (from 1) having
+// been deposited tenure-k+1 months before it.
+func recurringMaturity(deposit *models.RecurringDeposit, numbers []int) float64 {
+	total := 0.0
+	for _, number := range numbers {
+		total += compound(deposit.InstallmentAmount, deposit.InterestRate, deposit.Compounding, deposit.TenureMonths-number+1)
+	}
+	return roundMoney(total)
+}
+
+// DepositRates is the rate card deposits are opened at. Each product maps the shortest tenure in
+// months of a band to the annual rate for that band, a tenure gets the rate of the longest band
+// it reaches.
+type DepositRates struct {
+	Fixed     map[int]float64
+	Recurring map[int]float64
+}
+
+// the rate cards used unless FIXED_DEPOSIT_RATES or RECURRING_DEPOSIT_RATES say otherwise
+var (
+	DefaultFixedDepositRates     = map[int]float64{3: 4.5, 6: 5.5, 12: 6.5, 36: 7}
+	DefaultRecurringDepositRates = map[int]float64{6: 5, 12: 6, 36: 6.5}
+)
+
+// rateFor looks the tenure up on a product's card, false when it is shorter than every band.
+func rateFor(card map[int]float64, tenureMonths int) (float64, bool) {
+	band := -1
+	for months := range card {
+		if months <= tenureMonths && months > band {
+			band = months
+		}
+	}
+	if band < 0 {
+		return 0, false
+	}
+	return card[band], true
+}
+
+func depositRate(card map[int]float64, product string, tenureMonths int) (float64, error) {
+	rate, ok := rateFor(card, tenureMonths)
+	if !ok {
+		return 0, FieldValidationError(FieldError{Field: "tenure_months", Message: fmt.Sprintf("no %s deposit rate for %d months", product, tenureMonths)})
+	}
+	return rate, nil
+}
+
+type DepositService struct {
+	db    *gorm.DB
+	rates DepositRates
+}
+
+func NewDepositService(db *gorm.DB, rates DepositRates) *DepositService {
+	return &DepositService{db: db, rates: rates}
+}
+
+// OpenFixed funds the deposit from its account, which needs an instruction satisfying the
+// account's operating mandate like any withdrawal. The rate comes from the rate card.
+func (s *DepositService) OpenFixed(ctx context.Context, deposit *models.FixedDeposit, customerIDs []uint) error {
+	rate, err := depositRate(s.rates.Fixed, "fixed", deposit.TenureMonths)
+	if err != nil {
+		return err
+	}
+	deposit.InterestRate = rate
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, deposit.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanDebit(account); err != nil {
+			return err
+		}
+		if err := checkMandate(tx, account, customerIDs); err != nil {
+			return err
+		}
+
+		deposit.StartDate = time.Now()
+		deposit.MaturityDate = deposit.StartDate.AddDate(0, deposit.TenureMonths, 0)
+		deposit.MaturityAmount = roundMoney(compound(deposit.Principal, deposit.InterestRate, deposit.Compounding, deposit.TenureMonths))
+		deposit.Status = models.DepositActive
+		if err := tx.Create(deposit).Error; err != nil {
+			return dbError(err, "fixed_deposit")
+		}
+		_, err = postDebit(tx, account, "fixed_deposit", deposit.Principal, fmt.Sprintf("fixed deposit %d", deposit.ID))
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("fixed deposit failed", "account_id", deposit.AccountID, "principal", deposit.Principal, "error", err)
+		return err
+	}
+	logging.FromContext(ctx).Info("fixed deposit opened", "deposit_id", deposit.ID, "account_id", deposit.AccountID, "principal", deposit.Principal)
+	return nil
+}
+
+func (s *DepositService) GetFixed(ctx context.Context, id uint) (*models.FixedDeposit, error) {
+	var deposit models.FixedDeposit
+	if err := s.db.WithContext(ctx).First(&deposit, id).Error; err != nil {
+		return nil, dbError(err, "fixed_deposit")
+	}
+	return &deposit, nil
+}
+
+func (s *DepositService) GetAllFixed(ctx context.Context, accountID uint) ([]models.FixedDeposit, error) {
+	query := s.db.WithContext(ctx).Order("id asc")
+	if accountID != 0 {
+		query = query.Where("account_id = ?", accountID)
+	}
+	var deposits []models.FixedDeposit
+	if err := query.Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+// ChangeMaturityInstruction switches an active fixed deposit between payout and auto_renew. Like
+// opening it, the change needs an instruction satisfying the account's operating mandate.
+func (s *DepositService) ChangeMaturityInstruction(ctx context.Context, id uint, instruction string, customerIDs []uint) (*models.FixedDeposit, error) {
+	var deposit models.FixedDeposit
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error; err != nil {
+			return dbError(err, "fixed_deposit")
+		}
+		if deposit.Status != models.DepositActive {
+			return ConflictError("fixed_deposit_"+deposit.Status, "fixed deposit is "+deposit.Status)
+		}
+		var account models.Account
+		if err := tx.First(&account, deposit.AccountID).Error; err != nil {
+			return dbError(err, "account")
+		}
+		if err := checkMandate(tx, &account, customerIDs); err != nil {
+			return err
+		}
+
+		deposit.MaturityInstruction = instruction
+		deposit.Version++
+		return tx.Model(&deposit).Updates(map[string]interface{}{
+			"maturity_instruction": deposit.MaturityInstruction,
+			"version":              deposit.Version,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("fixed deposit maturity instruction changed", "deposit_id", deposit.ID, "maturity_instruction", deposit.MaturityInstruction)
+	return &deposit, nil
+}
+
+// OpenRecurring sets up the deposit and collects the first installment straight away, the rest
+// are debited monthly by CollectInstallments.
+func (s *DepositService) OpenRecurring(ctx context.Context, deposit *models.RecurringDeposit, customerIDs []uint) error {
+	rate, err := depositRate(s.rates.Recurring, "recurring", deposit.TenureMonths)
+	if err != nil {
+		return err
+	}
+	deposit.InterestRate = rate
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, deposit.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanDebit(account); err != nil {
+			return err
+		}
+		if err := checkMandate(tx, account, customerIDs); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		all := make([]int, deposit.TenureMonths)
+		for i := range all {
+			all[i] = i + 1
+		}
+		deposit.StartDate = now
+		deposit.MaturityDate = now.AddDate(0, deposit.TenureMonths, 0)
+		deposit.ExpectedMaturityAmount = recurringMaturity(deposit, all)
+		deposit.Status = models.DepositActive
+		if err := tx.Create(deposit).Error; err != nil {
+			return dbError(err, "recurring_deposit")
+		}
+
+		record, err := postDebit(tx, account, "recurring_deposit_installment", deposit.InstallmentAmount, fmt.Sprintf("recurring deposit %d installment 1", deposit.ID))
+		if err != nil {
+			return err
+		}
+		installment := models.RecurringDepositInstallment{
+			DepositID:     deposit.ID,
+			Number:        1,
+			DueDate:       now,
+			Amount:        deposit.InstallmentAmount,
+			Status:        models.RecurringInstallmentPaid,
+			TransactionID: &record.ID,
+			ProcessedAt:   now,
+		}
+		if err := tx.Create(&installment).Error; err != nil {
+			return err
+		}
+
+		deposit.InstallmentsPaid = 1
+		if deposit.TenureMonths > 1 {
+			next := now.AddDate(0, 1, 0)
+			deposit.NextDueDate = &next
+		}
+		return tx.Model(deposit).Updates(map[string]interface{}{
+			"installments_paid": deposit.InstallmentsPaid,
+			"next_due_date":     deposit.NextDueDate,
+		}).Error
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("recurring deposit failed", "account_id", deposit.AccountID, "installment", deposit.InstallmentAmount, "error", err)
+		return err
+	}
+	logging.FromContext(ctx).Info("recurring deposit opened", "deposit_id", deposit.ID, "account_id", deposit.AccountID, "installment", deposit.InstallmentAmount)
+	return nil
+}
+
+func (s *DepositService) GetRecurring(ctx context.Context, id uint) (*models.RecurringDeposit, error) {
+	var deposit models.RecurringDeposit
+	if err := s.db.WithContext(ctx).First(&deposit, id).Error; err != nil {
+		return nil, dbError(err, "recurring_deposit")
+	}
+	return &deposit, nil
+}
+
+func (s *DepositService) GetAllRecurring(ctx context.Context, accountID uint) ([]models.RecurringDeposit, error) {
+	query := s.db.WithContext(ctx).Order("id asc")
+	if accountID != 0 {
+		query = query.Where("account_id = ?", accountID)
+	}
+	var deposits []models.RecurringDeposit
+	if err := query.Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+func (s *DepositService) GetInstallments(ctx context.Context, id uint) ([]models.RecurringDepositInstallment, error) {
+	if _, err := s.GetRecurring(ctx, id); err != nil {
+		return nil, err
+	}
+	var installments []models.RecurringDepositInstallment
+	if err := s.db.WithContext(ctx).Where("deposit_id = ?", id).Order("number asc").Find(&installments).Error; err != nil {
+		return nil, err
+	}
+	return installments, nil
+}
+
+// CollectInstallments debits every recurring deposit installment due by now. An installment the
+// account can't pay (insufficient balance, frozen account, ...) is recorded as missed with the
+// reason, and the deposit moves on to the next month.
+func (s *DepositService) CollectInstallments(ctx context.Context, now time.Time) (paid, missed int, err error) {
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(&models.RecurringDeposit{}).
+		Where("status = ? AND next_due_date <= ?", models.DepositActive, now).
+		Order("next_due_date asc").Pluck("id", &ids).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		var status string
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var deposit models.RecurringDeposit
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error; err != nil {
+				return err
+			}
+			if deposit.Status != models.DepositActive || deposit.NextDueDate == nil || deposit.NextDueDate.After(now) {
+				return nil
+			}
+			number := deposit.InstallmentsPaid + deposit.InstallmentsMissed + 1
+			installment := models.RecurringDepositInstallment{
+				DepositID:   deposit.ID,
+				Number:      number,
+				DueDate:     *deposit.NextDueDate,
+				Amount:      deposit.InstallmentAmount,
+				ProcessedAt: now,
+			}
+
+			record, err := s.debitInstallment(tx, &deposit, number)
+			var domainErr *Error
+			switch {
+			case err == nil:
+				installment.Status = models.RecurringInstallmentPaid
+				installment.TransactionID = &record.ID
+				deposit.InstallmentsPaid++
+			case errors.As(err, &domainErr):
+				installment.Status = models.RecurringInstallmentMissed
+				installment.FailureReason = truncate(domainErr.Message, 255)
+				deposit.InstallmentsMissed++
+			default:
+				return err
+			}
+			if err := tx.Create(&installment).Error; err != nil {
+				return err
+			}
+			status = installment.Status
+
+			var next *time.Time
+			if number < deposit.TenureMonths {
+				due := deposit.StartDate.AddDate(0, number, 0)
+				next = &due
+			}
+			return tx.Model(&deposit).Updates(map[string]interface{}{
+				"installments_paid":   deposit.InstallmentsPaid,
+				"installments_missed": deposit.InstallmentsMissed,
+				"next_due_date":       next,
+				"version":             gorm.Expr("version + 1"),
+			}).Error
+		})
+		if err != nil {
+			return paid, missed, err
+		}
+		switch status {
+		case models.RecurringInstallmentPaid:
+			paid++
+		case models.RecurringInstallmentMissed:
+			missed++
+			logging.FromContext(ctx).Warn("recurring deposit installment missed", "deposit_id", id)
+		}
+	}
+	return paid, missed, nil
+}
+
+// debitInstallment takes one installment from the deposit's account inside a savepoint, so a
+// failed debit can be recorded as missed in the surrounding transaction.
+func (s *DepositService) debitInstallment(tx *gorm.DB, deposit *models.RecurringDeposit, number int) (*models.Transaction, error) {
+	var record *models.Transaction
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, deposit.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanDebit(account); err != nil {
+			return err
+		}
+		record, err = postDebit(tx, account, "recurring_deposit_installment", deposit.InstallmentAmount, fmt.Sprintf("recurring deposit %d installment %d", deposit.ID, number))
+		return err
+	})
+	return record, err
+}
+
+// ProcessMaturities settles fixed and recurring deposits that reached maturity by now. Fixed
+// deposits are paid out, or renewed for the same tenure at the rate the card now gives it.
+// Recurring deposits are paid out with interest on the installments actually collected. A
+// deposit whose account can't take the credit is left active and retried on the next run.
+func (s *DepositService) ProcessMaturities(ctx context.Context, now time.Time) (int, error) {
+	var fixedIDs, recurringIDs []uint
+	if err := s.db.WithContext(ctx).Model(&models.FixedDeposit{}).
+		Where("status = ? AND maturity_date <= ?", models.DepositActive, now).Pluck("id", &fixedIDs).Error; err != nil {
+		return 0, err
+	}
+	if err := s.db.WithContext(ctx).Model(&models.RecurringDeposit{}).
+		Where("status = ? AND maturity_date <= ? AND next_due_date IS NULL", models.DepositActive, now).Pluck("id", &recurringIDs).Error; err != nil {
+		return 0, err
+	}
+
+	matured := 0
+	for _, id := range fixedIDs {
+		if err := s.matureFixed(ctx, id, now); err != nil {
+			var domainErr *Error
+			if !errors.As(err, &domainErr) {
+				return matured, err
+			}
+			logging.FromContext(ctx).Warn("fixed deposit maturity deferred", "deposit_id", id, "error", err)
+			continue
+		}
+		matured++
+	}
+	for _, id := range recurringIDs {
+		if err := s.matureRecurring(ctx, id, now); err != nil {
+			var domainErr *Error
+			if !errors.As(err, &domainErr) {
+				return matured, err
+			}
+			logging.FromContext(ctx).Warn("recurring deposit maturity deferred", "deposit_id", id, "error", err)
+			continue
+		}
+		matured++
+	}
+	return matured, nil
+}
+
+func (s *DepositService) matureFixed(ctx context.Context, id uint, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deposit models.FixedDeposit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error; err != nil {
+			return err
+		}
+		if deposit.Status != models.DepositActive {
+			return nil
+		}
+
+		if deposit.MaturityInstruction == models.MaturityAutoRenew {
+			//a tenure taken off the card keeps the rate it had
+			rate, ok := rateFor(s.rates.Fixed, deposit.TenureMonths)
+			if !ok {
+				rate = deposit.InterestRate
+			}
+			renewal := models.FixedDeposit{
+				AccountID:           deposit.AccountID,
+				Principal:           deposit.MaturityAmount,
+				InterestRate:        rate,
+				TenureMonths:        deposit.TenureMonths,
+				Compounding:         deposit.Compounding,
+				MaturityInstruction: deposit.MaturityInstruction,
+				StartDate:           deposit.MaturityDate,
+				MaturityDate:        deposit.MaturityDate.AddDate(0, deposit.TenureMonths, 0),
+				Status:              models.DepositActive,
+				RenewedFromID:       &deposit.ID,
+			}
+			renewal.MaturityAmount = roundMoney(compound(renewal.Principal, renewal.InterestRate, renewal.Compounding, renewal.TenureMonths))
+			if err := tx.Create(&renewal).Error; err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("fixed deposit renewed", "deposit_id", deposit.ID, "renewal_id", renewal.ID, "principal", renewal.Principal)
+			return tx.Model(&deposit).Updates(map[string]interface{}{
+				"status":     models.DepositRenewed,
+				"matured_at": now,
+				"version":    gorm.Expr("version + 1"),
+			}).Error
+		}
+
+		account, err := lockAccount(tx, deposit.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanCredit(tx, account); err != nil {
+			return err
+		}
+		if _, err := postCredit(tx, account, "fixed_deposit_maturity", deposit.MaturityAmount, fmt.Sprintf("maturity of fixed deposit %d", deposit.ID)); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("fixed deposit matured", "deposit_id", deposit.ID, "account_id", deposit.AccountID, "amount", deposit.MaturityAmount)
+		return tx.Model(&deposit).Updates(map[string]interface{}{
+			"status":     models.DepositMatured,
+			"matured_at": now,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	})
+}
+
+func (s *DepositService) matureRecurring(ctx context.Context, id uint, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var deposit models.RecurringDeposit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error; err != nil {
+			return err
+		}
+		if deposit.Status != models.DepositActive {
+			return nil
+		}
+
+		var numbers []int
+		if err := tx.Model(&models.RecurringDepositInstallment{}).
+			Where("deposit_id = ? AND status = ?", deposit.ID, models.RecurringInstallmentPaid).
+			Pluck("number", &numbers).Error; err != nil {
+			return err
+		}
+		amount := recurringMaturity(&deposit, numbers)
+
+		account, err := lockAccount(tx, deposit.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanCredit(tx, account); err != nil {
+			return err
+		}
+		if amount > 0 {
+			if _, err := postCredit(tx, account, "recurring_deposit_maturity", amount, fmt.Sprintf("maturity of recurring deposit %d", deposit.ID)); err != nil {
+				return err
+			}
+		}
+		logging.FromContext(ctx).Info("recurring deposit matured", "deposit_id", deposit.ID, "account_id", deposit.AccountID, "amount", amount)
+		return tx.Model(&deposit).Updates(map[string]interface{}{
+			"status":          models.DepositMatured,
+			"maturity_amount": amount,
+			"matured_at":      now,
+			"version":         gorm.Expr("version + 1"),
+		}).Error
+	})
+}
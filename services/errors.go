@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type ErrorKind string
+
+const (
+	KindNotFound             ErrorKind = "not_found"
+	KindConflict             ErrorKind = "conflict"
+	KindInsufficientFunds    ErrorKind = "insufficient_funds"
+	KindValidation           ErrorKind = "validation"
+	KindPreconditionFailed   ErrorKind = "precondition_failed"
+	KindPreconditionRequired ErrorKind = "precondition_required"
+	KindForbidden            ErrorKind = "forbidden"
+	KindLimitExceeded        ErrorKind = "limit_exceeded"
+)
+
+// Error is the domain error returned by services. Code is a stable machine-readable identifier
+// clients can switch on, Message is meant for humans. Limit names the limit that tripped for
+// limit errors.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Limit   string
+	Err     error
+}
+
+// FieldError points at a single invalid request field, using its JSON name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFoundError(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func ConflictError(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func InsufficientFundsError(code, message string) *Error {
+	return &Error{Kind: KindInsufficientFunds, Code: code, Message: message}
+}
+
+func LimitExceededError(limit, message string) *Error {
+	return &Error{Kind: KindLimitExceeded, Code: "limit_exceeded", Message: message, Limit: limit}
+}
+
+func ValidationError(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+func PreconditionFailedError(code, message string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Code: code, Message: message}
+}
+
+func PreconditionRequiredError(code, message string) *Error {
+	return &Error{Kind: KindPreconditionRequired, Code: code, Message: message}
+}
+
+func ForbiddenError(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func FieldValidationError(fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "request validation failed", Fields: fields}
+}
+
+// dbError maps GORM errors for the given entity onto domain errors, anything it doesn't
+// recognise is passed through and treated as an internal error by the caller.
+func dbError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Kind: KindNotFound, Code: entity + "_not_found", Message: entity + " not found", Err: err}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Kind: KindConflict, Code: entity + "_already_exists", Message: entity + " conflicts with an existing record", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Kind: KindConflict, Code: entity + "_reference_violation", Message: entity + " references a missing record or is still referenced", Err: err}
+	}
+	return err
+}
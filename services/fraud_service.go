@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// monitoring rules, stored as the alert's Rule
+const (
+	RuleStructuring     = "structuring"
+	RuleRapidMovement   = "rapid_movement"
+	RuleLargeWithdrawal = "large_withdrawal"
+	RuleDormantActivity = "dormant_account_activity"
+)
+
+// transaction types that bring money in from outside the account's own products
+var inflowTypes = []string{"deposit", "transfer_in"}
+
+type FraudPolicy struct {
+	// StructuringThreshold is the reporting threshold; StructuringCount deposits within
+	// StructuringMarginPercent below it inside StructuringWindow raise an alert
+	StructuringThreshold     float64
+	StructuringMarginPercent float64
+	StructuringCount         int
+	StructuringWindow        time.Duration
+	// RapidOutPercent of at least RapidMinAmount coming in leaving again within RapidWindow
+	RapidWindow     time.Duration
+	RapidMinAmount  float64
+	RapidOutPercent float64
+	// LargeWithdrawalMultiple of the average withdrawal over LargeLookbackDays, for accounts with
+	// at least LargeMinHistory withdrawals; twice the multiple is critical
+	LargeWithdrawalMultiple float64
+	LargeLookbackDays       int
+	LargeMinHistory         int
+	// DormantWindow is how long after reactivation a dormant account's activity is flagged
+	DormantWindow time.Duration
+	// AutoFreeze freezes the account when a critical alert is raised
+	AutoFreeze bool
+	// InvestigatorRoles may work the alert queue
+	InvestigatorRoles []string
+}
+
+type fraudRule func(db *gorm.DB, account *models.Account, t *models.Transaction) (*models.FraudAlert, error)
+
+type FraudService struct {
+	db     *gorm.DB
+	policy FraudPolicy
+}
+
+// NewFraudService hooks the monitoring rules into every transaction insert on db, including the
+// ones made by background jobs, so alerts are raised in the same database transaction as the
+// money movement.
+func NewFraudService(db *gorm.DB, policy FraudPolicy) *FraudService {
+	s := &FraudService{db: db, policy: policy}
+	if err := db.Callback().Create().After("gorm:create").Register("fraud:monitor", s.monitor); err != nil {
+		slog.Error("failed to register transaction monitoring", "error", err)
+	}
+	return s
+}
+
+func (s *FraudService) monitor(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "transactions" {
+		return
+	}
+	var records []*models.Transaction
+	switch dest := tx.Statement.Dest.(type) {
+	case *models.Transaction:
+		records = append(records, dest)
+	case *[]models.Transaction:
+		for i := range *dest {
+			records = append(records, &(*dest)[i])
+		}
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	for _, record := range records {
+		if err := s.evaluate(db, record); err != nil {
+			_ = tx.AddError(err)
+			return
+		}
+	}
+}
+
+// evaluate runs every rule on a new transaction and stores the alerts they raise.
+func (s *FraudService) evaluate(db *gorm.DB, t *models.Transaction) error {
+	var account models.Account
+	if err := db.First(&account, t.AccountID).Error; err != nil {
+		return err
+	}
+
+	rules := []fraudRule{s.structuring, s.rapidMovement, s.largeWithdrawal, s.dormantActivity}
+	for _, rule := range rules {
+		alert, err := rule(db, &account, t)
+		if err != nil {
+			return err
+		}
+		if alert == nil {
+			continue
+		}
+
+		alert.AccountID = account.ID
+		alert.TransactionID = &t.ID
+		alert.Status = models.AlertOpen
+		if err := db.Create(alert).Error; err != nil {
+			return err
+		}
+		logging.FromContext(db.Statement.Context).Warn("fraud alert raised", "alert_id", alert.ID, "rule", alert.Rule, "severity", alert.Severity, "account_id", account.ID, "transaction_id", t.ID)
+		if alert.Severity == models.SeverityCritical && s.policy.AutoFreeze {
+			s.freezeAfterCommit(db.Statement.Context, alert)
+		}
+	}
+	return nil
+}
+
+// freezeAfterCommit freezes the alert's account once the transaction that raised the alert is
+// over. Freezing inside it would change the account under the caller, which goes on working with
+// its own copy (a close would overwrite the freeze, a transfer would post its second leg). The
+// account lock is only granted after that transaction ends, and the alert only exists if it
+// committed.
+func (s *FraudService) freezeAfterCommit(ctx context.Context, alert *models.FraudAlert) {
+	ctx = context.WithoutCancel(ctx)
+	alertID, accountID, rule := alert.ID, alert.AccountID, alert.Rule
+	go func() {
+		frozen := false
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			account, err := lockAccount(tx, accountID)
+			if err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&models.FraudAlert{}).Where("id = ?", alertID).Count(&count).Error; err != nil {
+				return err
+			}
+			//rolled back along with the transaction that raised it
+			if count == 0 {
+				return nil
+			}
+			if account.Status != models.AccountStatusOpen && account.Status != models.AccountStatusDormant {
+				return nil
+			}
+			if err := transitionStatus(tx, account, models.AccountStatusFrozen, "frozen on critical "+rule+" alert"); err != nil {
+				return err
+			}
+			frozen = true
+			return tx.Model(&models.FraudAlert{}).Where("id = ?", alertID).Update("account_frozen", true).Error
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to freeze account on fraud alert", "alert_id", alertID, "account_id", accountID, "error", err)
+			return
+		}
+		if frozen {
+			logging.FromContext(ctx).Warn("account frozen on fraud alert", "alert_id", alertID, "account_id", accountID)
+		}
+	}()
+}
+
+// alerted reports whether the rule already raised an alert on the account since the given time,
+// so a pattern that keeps going is not reported on every transaction.
+func alerted(db *gorm.DB, accountID uint, rule string, since time.Time) (bool, error) {
+	var count int64
+	err := db.Model(&models.FraudAlert{}).Where("account_id = ? AND rule = ? AND created_at >= ?", accountID, rule, since).Count(&count).Error
+	return count > 0, err
+}
+
+// structuring flags deposits kept just under the reporting threshold.
+func (s *FraudService) structuring(db *gorm.DB, account *models.Account, t *models.Transaction) (*models.FraudAlert, error) {
+	p := s.policy
+	if t.Type != "deposit" || p.StructuringThreshold <= 0 {
+		return nil, nil
+	}
+	floor := p.StructuringThreshold * (1 - p.StructuringMarginPercent/100)
+	if t.Amount < floor || t.Amount >= p.StructuringThreshold {
+		return nil, nil
+	}
+
+	since := t.CreatedAt.Add(-p.StructuringWindow)
+	var count int64
+	if err := db.Model(&models.Transaction{}).
+		Where("account_id = ? AND type = ? AND amount >= ? AND amount < ? AND transaction_date >= ?", account.ID, "deposit", floor, p.StructuringThreshold, since).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count < int64(p.StructuringCount) {
+		return nil, nil
+	}
+	if done, err := alerted(db, account.ID, RuleStructuring, since); err != nil || done {
+		return nil, err
+	}
+	return &models.FraudAlert{
+		Rule:        RuleStructuring,
+		Severity:    models.SeverityHigh,
+		Description: fmt.Sprintf("%d deposits between %.2f and %.2f within %s", count, floor, p.StructuringThreshold, p.StructuringWindow),
+	}, nil
+}
+
+// rapidMovement flags money leaving soon after it came in.
+func (s *FraudService) rapidMovement(db *gorm.DB, account *models.Account, t *models.Transaction) (*models.FraudAlert, error) {
+	p := s.policy
+	if !isLimitedType(t.Type) {
+		return nil, nil
+	}
+
+	since := t.CreatedAt.Add(-p.RapidWindow)
+	var in, out float64
+	if err := db.Model(&models.Transaction{}).Select("COALESCE(SUM(amount), 0)").
+		Where("account_id = ? AND type IN ? AND transaction_date >= ?", account.ID, inflowTypes, since).
+		Scan(&in).Error; err != nil {
+		return nil, err
+	}
+	if in < p.RapidMinAmount || in == 0 {
+		return nil, nil
+	}
+	if err := db.Model(&models.Transaction{}).Select("COALESCE(SUM(amount), 0)").
+		Where("account_id = ? AND type IN ? AND transaction_date >= ?", account.ID, limitedTypes, since).
+		Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	if out < in*p.RapidOutPercent/100 {
+		return nil, nil
+	}
+	if done, err := alerted(db, account.ID, RuleRapidMovement, since); err != nil || done {
+		return nil, err
+	}
+	return &models.FraudAlert{
+		Rule:        RuleRapidMovement,
+		Severity:    models.SeverityHigh,
+		Description: fmt.Sprintf("%.2f moved out within %s of %.2f coming in", out, p.RapidWindow, in),
+	}, nil
+}
+
+// largeWithdrawal flags a withdrawal far above what the account usually takes out.
+func (s *FraudService) largeWithdrawal(db *gorm.DB, account *models.Account, t *models.Transaction) (*models.FraudAlert, error) {
+	p := s.policy
+	if !isLimitedType(t.Type) || p.LargeWithdrawalMultiple <= 0 {
+		return nil, nil
+	}
+
+	var history struct {
+		Count   int64
+		Average float64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(amount), 0) AS average").
+		Where("account_id = ? AND type IN ? AND transaction_date >= ? AND id <> ?", account.ID, limitedTypes, t.CreatedAt.AddDate(0, 0, -p.LargeLookbackDays), t.ID).
+		Scan(&history).Error; err != nil {
+		return nil, err
+	}
+	if history.Count < int64(p.LargeMinHistory) || history.Average == 0 {
+		return nil, nil
+	}
+	ratio := t.Amount / history.Average
+	if ratio < p.LargeWithdrawalMultiple {
+		return nil, nil
+	}
+	severity := models.SeverityHigh
+	if ratio >= 2*p.LargeWithdrawalMultiple {
+		severity = models.SeverityCritical
+	}
+	return &models.FraudAlert{
+		Rule:        RuleLargeWithdrawal,
+		Severity:    severity,
+		Description: fmt.Sprintf("%.2f is %.1f times the average withdrawal of %.2f over %d days", t.Amount, ratio, history.Average, p.LargeLookbackDays),
+	}, nil
+}
+
+// dormantActivity flags the first activity on an account shortly after it was reactivated from
+// dormancy, money leaving it is treated as more serious than money coming in.
+func (s *FraudService) dormantActivity(db *gorm.DB, account *models.Account, t *models.Transaction) (*models.FraudAlert, error) {
+	var changes []models.AccountStatusChange
+	if err := db.Where("account_id = ?", account.ID).Order("id desc").Limit(1).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	change := changes[0]
+	if change.FromStatus != models.AccountStatusDormant || change.ToStatus != models.AccountStatusOpen ||
+		change.CreatedAt.Before(t.CreatedAt.Add(-s.policy.DormantWindow)) {
+		return nil, nil
+	}
+
+	severity := models.SeverityMedium
+	if !creditTypes[t.Type] {
+		severity = models.SeverityHigh
+	}
+	//a withdrawal after the reactivating deposit still deserves its own, more severe alert
+	var count int64
+	if err := db.Model(&models.FraudAlert{}).
+		Where("account_id = ? AND rule = ? AND severity = ? AND created_at >= ?", account.ID, RuleDormantActivity, severity, change.CreatedAt).
+		Count(&count).Error; err != nil || count > 0 {
+		return nil, err
+	}
+	return &models.FraudAlert{
+		Rule:        RuleDormantActivity,
+		Severity:    severity,
+		Description: fmt.Sprintf("%s of %.2f on an account reactivated from dormancy on %s", t.Type, t.Amount, change.CreatedAt.Format("2006-01-02")),
+	}, nil
+}
+
+func isLimitedType(txType string) bool {
+	for _, t := range limitedTypes {
+		if t == txType {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertFilter narrows the alert queue, empty fields match everything.
+type AlertFilter struct {
+	Status    string
+	Severity  string
+	AccountID uint
+}
+
+// GetAll returns the queue most severe first, oldest first within a severity.
+func (s *FraudService) GetAll(ctx context.Context, filter AlertFilter) ([]models.FraudAlert, error) {
+	query := s.db.WithContext(ctx)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Severity != "" {
+		query = query.Where("severity = ?", filter.Severity)
+	}
+	if filter.AccountID != 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	var alerts []models.FraudAlert
+	if err := query.Order("CASE severity WHEN 'critical' THEN 0 WHEN 'high' THEN 1 WHEN 'medium' THEN 2 ELSE 3 END, created_at asc").
+		Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func (s *FraudService) GetByID(ctx context.Context, id uint) (*models.FraudAlert, error) {
+	var alert models.FraudAlert
+	if err := s.db.WithContext(ctx).First(&alert, id).Error; err != nil {
+		return nil, dbError(err, "fraud_alert")
+	}
+	return &alert, nil
+}
+
+// Assign hands an open alert to an investigator, the caller when assignee is empty.
+func (s *FraudService) Assign(ctx context.Context, id uint, assignee string) (*models.FraudAlert, error) {
+	actor, err := s.investigator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if assignee == "" {
+		assignee = actor.ID
+	}
+	return s.update(ctx, id, func(alert *models.FraudAlert) map[string]interface{} {
+		alert.Status = models.AlertInvestigating
+		alert.AssignedTo = assignee
+		return map[string]interface{}{"status": alert.Status, "assigned_to": alert.AssignedTo}
+	})
+}
+
+// Resolve closes an alert as confirmed or dismissed. It doesn't touch the account, a frozen
+// account is unfrozen through the account lifecycle.
+func (s *FraudService) Resolve(ctx context.Context, id uint, outcome, note string) (*models.FraudAlert, error) {
+	actor, err := s.investigator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return s.update(ctx, id, func(alert *models.FraudAlert) map[string]interface{} {
+		alert.Status = outcome
+		alert.ResolutionNote = note
+		alert.ResolvedBy = actor.ID
+		alert.ResolvedAt = &now
+		if alert.AssignedTo == "" {
+			alert.AssignedTo = actor.ID
+		}
+		return map[string]interface{}{
+			"status":          alert.Status,
+			"resolution_note": alert.ResolutionNote,
+			"resolved_by":     alert.ResolvedBy,
+			"resolved_at":     now,
+			"assigned_to":     alert.AssignedTo,
+		}
+	})
+}
+
+func (s *FraudService) update(ctx context.Context, id uint, change func(*models.FraudAlert) map[string]interface{}) (*models.FraudAlert, error) {
+	var alert models.FraudAlert
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&alert, id).Error; err != nil {
+			return dbError(err, "fraud_alert")
+		}
+		if alert.Status == models.AlertConfirmed || alert.Status == models.AlertDismissed {
+			return ConflictError("alert_resolved", "alert is already "+alert.Status)
+		}
+		changes := change(&alert)
+		alert.Version++
+		changes["version"] = alert.Version
+		return tx.Model(&alert).Updates(changes).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("fraud alert updated", "alert_id", id, "status", alert.Status, "assigned_to", alert.AssignedTo)
+	return &alert, nil
+}
+
+func (s *FraudService) investigator(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return actor, ForbiddenError("actor_required", "X-User-ID is required to work fraud alerts")
+	}
+	for _, role := range s.policy.InvestigatorRoles {
+		if role == actor.Role {
+			return actor, nil
+		}
+	}
+	return actor, ForbiddenError("not_an_investigator", "role "+actor.Role+" cannot work fraud alerts")
+}
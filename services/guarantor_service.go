@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+type GuarantorService struct {
+	db *gorm.DB
+}
+
+func NewGuarantorService(db *gorm.DB) *GuarantorService {
+	return &GuarantorService{db: db}
+}
+
+// Offer adds a guarantor to an application that is still waiting for a decision.
+func (s *GuarantorService) Offer(ctx context.Context, applicationID uint, guarantor *models.LoanGuarantor) error {
+	db := s.db.WithContext(ctx)
+	application, err := undecidedApplication(db, applicationID)
+	if err != nil {
+		return err
+	}
+	if err := checkGuarantor(db, guarantor, application.CustomerID, application.RequestedAmount); err != nil {
+		return err
+	}
+	guarantor.ApplicationID = applicationID
+	guarantor.LoanID = nil
+	guarantor.Status = models.GuaranteePending
+	return dbError(db.Create(guarantor).Error, "guarantor")
+}
+
+// AddToLoan brings in a further guarantor on an ongoing loan, active straight away.
+func (s *GuarantorService) AddToLoan(ctx context.Context, loanID uint, guarantor *models.LoanGuarantor) error {
+	db := s.db.WithContext(ctx)
+	loan, err := ongoingLoan(db, loanID)
+	if err != nil {
+		return err
+	}
+	if err := checkGuarantor(db, guarantor, loan.CustomerID, loan.Amount); err != nil {
+		return err
+	}
+	if loan.ApplicationID == nil {
+		return ValidationError("loan_without_application", fmt.Sprintf("loan %d was not booked from an application", loan.ID))
+	}
+	guarantor.ApplicationID = *loan.ApplicationID
+	guarantor.LoanID = &loan.ID
+	guarantor.Status = models.GuaranteeActive
+	return dbError(db.Create(guarantor).Error, "guarantor")
+}
+
+func checkGuarantor(db *gorm.DB, guarantor *models.LoanGuarantor, borrowerID uint, amount float64) error {
+	if err := ensureExists(db, &models.Customer{}, guarantor.CustomerID, "customer_id", "customer"); err != nil {
+		return err
+	}
+	if guarantor.CustomerID == borrowerID {
+		return FieldValidationError(FieldError{Field: "customer_id", Message: "the borrower cannot guarantee their own loan"})
+	}
+	if guarantor.GuaranteeAmount > amount {
+		return FieldValidationError(FieldError{Field: "guarantee_amount", Message: "must not exceed the loan amount"})
+	}
+	return nil
+}
+
+func (s *GuarantorService) GetForApplication(ctx context.Context, applicationID uint) ([]models.LoanGuarantor, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.LoanApplication{}, applicationID, "application_id", "loan application"); err != nil {
+		return nil, err
+	}
+	var guarantors []models.LoanGuarantor
+	if err := s.db.WithContext(ctx).Where("application_id = ?", applicationID).Order("id asc").Find(&guarantors).Error; err != nil {
+		return nil, err
+	}
+	return guarantors, nil
+}
+
+func (s *GuarantorService) GetForLoan(ctx context.Context, loanID uint) ([]models.LoanGuarantor, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Loan{}, loanID, "loan_id", "loan"); err != nil {
+		return nil, err
+	}
+	var guarantors []models.LoanGuarantor
+	if err := s.db.WithContext(ctx).Where("loan_id = ?", loanID).Order("id asc").Find(&guarantors).Error; err != nil {
+		return nil, err
+	}
+	return guarantors, nil
+}
+
+// Release discharges a guarantor once the loan they stood in for is closed.
+func (s *GuarantorService) Release(ctx context.Context, loanID, id uint) (*models.LoanGuarantor, error) {
+	db := s.db.WithContext(ctx)
+	var loan models.Loan
+	if err := db.First(&loan, loanID).Error; err != nil {
+		return nil, dbError(err, "loan")
+	}
+	if loan.Status != "closed" {
+		return nil, ConflictError("loan_not_closed", "guarantors are released once the loan is closed")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.LoanGuarantor{}).
+		Where("id = ? AND loan_id = ? AND status = ?", id, loanID, models.GuaranteeActive).
+		Updates(map[string]interface{}{
+			"status":      models.GuaranteeReleased,
+			"released_at": now,
+			"version":     gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var guarantor models.LoanGuarantor
+	if err := db.Where("loan_id = ?", loanID).First(&guarantor, id).Error; err != nil {
+		return nil, dbError(err, "guarantor")
+	}
+	if result.RowsAffected == 0 {
+		return nil, ConflictError("guarantee_not_active", "guarantee is already "+guarantor.Status)
+	}
+	return &guarantor, nil
+}
+
+// guarantees lists what the customer has guaranteed on booked loans that are not yet released,
+// and the exposure: for each, the guarantee capped at the principal still outstanding.
+func guarantees(db *gorm.DB, customerID uint) ([]models.LoanGuarantor, float64, error) {
+	var guarantors []models.LoanGuarantor
+	if err := db.Preload("Loan").
+		Where("customer_id = ? AND status = ?", customerID, models.GuaranteeActive).
+		Order("id asc").Find(&guarantors).Error; err != nil {
+		return nil, 0, err
+	}
+
+	exposure := 0.0
+	for _, guarantor := range guarantors {
+		if guarantor.Loan == nil || guarantor.Loan.Status == "closed" {
+			continue
+		}
+		outstanding, err := principalOutstanding(db, guarantor.Loan.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		exposure += math.Min(guarantor.GuaranteeAmount, outstanding)
+	}
+	return guarantors, roundMoney(exposure), nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"banking_system/config"
+
+	"gorm.io/gorm"
+)
+
+type HealthService struct {
+	db *gorm.DB
+}
+
+func NewHealthService(db *gorm.DB) *HealthService {
+	return &HealthService{db: db}
+}
+
+func (s *HealthService) PingDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *HealthService) CheckMigrations(ctx context.Context) error {
+	version, err := config.AppliedVersion(s.db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if version < config.SchemaVersion {
+		return fmt.Errorf("schema version %d is behind expected version %d", version, config.SchemaVersion)
+	}
+	return nil
+}
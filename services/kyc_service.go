@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+	"banking_system/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type KYCPolicy struct {
+	// ReviewerRoles may verify or reject a customer's KYC
+	ReviewerRoles []string
+	// ValidityMonths is how long a verification lasts before it expires
+	ValidityMonths int
+	// MaxDocumentBytes caps the size of an uploaded document
+	MaxDocumentBytes int64
+}
+
+// ensureKYCVerified refuses customers whose KYC isn't verified, or whose verification has run out
+// even if the expiry sweep hasn't caught up with it yet.
+func ensureKYCVerified(customer *models.Customer, now time.Time) error {
+	status := customer.KYCStatus
+	if status == models.KYCVerified && customer.KYCExpiresAt != nil && !customer.KYCExpiresAt.After(now) {
+		status = models.KYCExpired
+	}
+	if status != models.KYCVerified {
+		return ConflictError("kyc_not_verified", "customer KYC is "+status)
+	}
+	return nil
+}
+
+type KYCService struct {
+	db     *gorm.DB
+	store  storage.BlobStore
+	policy KYCPolicy
+}
+
+func NewKYCService(db *gorm.DB, store storage.BlobStore, policy KYCPolicy) *KYCService {
+	return &KYCService{db: db, store: store, policy: policy}
+}
+
+// KYCProfile is everything collected on a customer for KYC.
+type KYCProfile struct {
+	Customer   models.Customer           `json:"customer"`
+	Addresses  []models.CustomerAddress  `json:"addresses"`
+	Identities []models.CustomerIdentity `json:"identities"`
+	Documents  []models.CustomerDocument `json:"documents"`
+}
+
+func (s *KYCService) GetProfile(ctx context.Context, customerID uint) (*KYCProfile, error) {
+	db := s.db.WithContext(ctx)
+	var profile KYCProfile
+	if err := db.First(&profile.Customer, customerID).Error; err != nil {
+		return nil, dbError(err, "customer")
+	}
+	if err := db.Where("customer_id = ?", customerID).Order("id asc").Find(&profile.Addresses).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("customer_id = ?", customerID).Order("id asc").Find(&profile.Identities).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("customer_id = ?", customerID).Order("id asc").Find(&profile.Documents).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (s *KYCService) AddAddress(ctx context.Context, customerID uint, address *models.CustomerAddress) error {
+	if err := s.ensureCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	address.CustomerID = customerID
+	return dbError(s.db.WithContext(ctx).Create(address).Error, "customer_address")
+}
+
+func (s *KYCService) DeleteAddress(ctx context.Context, customerID, addressID uint) error {
+	result := s.db.WithContext(ctx).Where("customer_id = ?", customerID).Delete(&models.CustomerAddress{}, addressID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return NotFoundError("customer_address_not_found", "customer_address not found")
+	}
+	return nil
+}
+
+func (s *KYCService) AddIdentity(ctx context.Context, customerID uint, identity *models.CustomerIdentity) error {
+	if err := s.ensureCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	if identity.IssuedAt != nil && identity.ExpiresAt != nil && !identity.ExpiresAt.After(*identity.IssuedAt) {
+		return FieldValidationError(FieldError{Field: "expires_at", Message: "expires_at must be after issued_at"})
+	}
+	identity.CustomerID = customerID
+	return dbError(s.db.WithContext(ctx).Create(identity).Error, "customer_identity")
+}
+
+// UploadDocument stores the content in the blob store and records its metadata. The blob is
+// removed again if the metadata can't be saved.
+func (s *KYCService) UploadDocument(ctx context.Context, customerID uint, document *models.CustomerDocument, content io.Reader) error {
+	if err := s.ensureCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	if document.IdentityID != nil {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.CustomerIdentity{}).
+			Where("id = ? AND customer_id = ?", *document.IdentityID, customerID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			err := FieldValidationError(FieldError{Field: "identity_id", Message: "identity does not belong to the customer"})
+			err.Code = "invalid_reference"
+			return err
+		}
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("customers/%d/%s", customerID, hex.EncodeToString(suffix))
+
+	//read one byte past the cap to tell a file of exactly the maximum size from a bigger one
+	hash := sha256.New()
+	counter := &countingReader{r: io.LimitReader(content, s.policy.MaxDocumentBytes+1)}
+	if err := s.store.Put(ctx, key, io.TeeReader(counter, hash)); err != nil {
+		return fmt.Errorf("failed to store document: %w", err)
+	}
+	if counter.n > s.policy.MaxDocumentBytes {
+		s.removeBlob(ctx, key)
+		return ValidationError("document_too_large", fmt.Sprintf("document exceeds %d bytes", s.policy.MaxDocumentBytes))
+	}
+	if counter.n == 0 {
+		s.removeBlob(ctx, key)
+		return ValidationError("document_empty", "document is empty")
+	}
+
+	document.CustomerID = customerID
+	document.Size = counter.n
+	document.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	document.StorageKey = key
+	if actor, ok := ActorFromContext(ctx); ok {
+		document.UploadedBy = actor.ID
+	}
+	if err := s.db.WithContext(ctx).Create(document).Error; err != nil {
+		s.removeBlob(ctx, key)
+		return dbError(err, "customer_document")
+	}
+
+	logging.FromContext(ctx).Info("customer document uploaded", "customer_id", customerID, "document_id", document.ID, "document_type", document.Type, "size", document.Size)
+	return nil
+}
+
+func (s *KYCService) removeBlob(ctx context.Context, key string) {
+	if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logging.FromContext(ctx).Warn("failed to remove document blob", "key", key, "error", err)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// OpenDocument returns the document's metadata and its content, which the caller has to close.
+func (s *KYCService) OpenDocument(ctx context.Context, customerID, documentID uint) (*models.CustomerDocument, io.ReadCloser, error) {
+	var document models.CustomerDocument
+	if err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).First(&document, documentID).Error; err != nil {
+		return nil, nil, dbError(err, "customer_document")
+	}
+	content, err := s.store.Get(ctx, document.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, NotFoundError("document_content_missing", "document content is missing from storage")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &document, content, nil
+}
+
+// Verify marks the customer's KYC verified for ValidityMonths. It needs a date of birth, an
+// address, an identity that hasn't expired and at least one document.
+func (s *KYCService) Verify(ctx context.Context, customerID uint, note string) (*models.Customer, error) {
+	reviewer, err := s.reviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return s.review(ctx, customerID, func(tx *gorm.DB, customer *models.Customer) error {
+		if customer.DateOfBirth == nil {
+			return ConflictError("kyc_incomplete", "date of birth is missing")
+		}
+		var addresses, identities, documents int64
+		if err := tx.Model(&models.CustomerAddress{}).Where("customer_id = ?", customer.ID).Count(&addresses).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CustomerIdentity{}).
+			Where("customer_id = ? AND (expires_at IS NULL OR expires_at > ?)", customer.ID, now).
+			Count(&identities).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CustomerDocument{}).Where("customer_id = ?", customer.ID).Count(&documents).Error; err != nil {
+			return err
+		}
+		switch {
+		case addresses == 0:
+			return ConflictError("kyc_incomplete", "no address on file")
+		case identities == 0:
+			return ConflictError("kyc_incomplete", "no unexpired government ID on file")
+		case documents == 0:
+			return ConflictError("kyc_incomplete", "no documents uploaded")
+		}
+
+		expires := now.AddDate(0, s.policy.ValidityMonths, 0)
+		customer.KYCStatus = models.KYCVerified
+		customer.KYCVerifiedAt = &now
+		customer.KYCReviewedBy = reviewer.ID
+		customer.KYCNote = note
+		customer.KYCExpiresAt = &expires
+		return nil
+	})
+}
+
+func (s *KYCService) Reject(ctx context.Context, customerID uint, reason string) (*models.Customer, error) {
+	reviewer, err := s.reviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.review(ctx, customerID, func(tx *gorm.DB, customer *models.Customer) error {
+		customer.KYCStatus = models.KYCRejected
+		customer.KYCVerifiedAt = nil
+		customer.KYCReviewedBy = reviewer.ID
+		customer.KYCNote = reason
+		customer.KYCExpiresAt = nil
+		return nil
+	})
+}
+
+func (s *KYCService) review(ctx context.Context, customerID uint, decide func(*gorm.DB, *models.Customer) error) (*models.Customer, error) {
+	var customer models.Customer
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&customer, customerID).Error; err != nil {
+			return dbError(err, "customer")
+		}
+		if err := decide(tx, &customer); err != nil {
+			return err
+		}
+		customer.Version++
+		return tx.Model(&customer).Updates(map[string]interface{}{
+			"kyc_status":      customer.KYCStatus,
+			"kyc_verified_at": customer.KYCVerifiedAt,
+			"kyc_reviewed_by": customer.KYCReviewedBy,
+			"kyc_note":        customer.KYCNote,
+			"kyc_expires_at":  customer.KYCExpiresAt,
+			"version":         customer.Version,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("customer kyc reviewed", "customer_id", customerID, "kyc_status", customer.KYCStatus, "by", customer.KYCReviewedBy)
+	return &customer, nil
+}
+
+func (s *KYCService) reviewer(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return actor, ForbiddenError("actor_required", "X-User-ID is required to review KYC")
+	}
+	for _, role := range s.policy.ReviewerRoles {
+		if role == actor.Role {
+			return actor, nil
+		}
+	}
+	return actor, ForbiddenError("not_a_kyc_reviewer", "role "+actor.Role+" cannot review KYC")
+}
+
+func (s *KYCService) ensureCustomer(ctx context.Context, customerID uint) error {
+	var customer models.Customer
+	if err := s.db.WithContext(ctx).First(&customer, customerID).Error; err != nil {
+		return dbError(err, "customer")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"math"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+// transaction types that add to the balance, every other type takes money out
+var creditTypes = map[string]bool{
+	"deposit":                    true,
+	"loan_disbursement":          true,
+	"loan_refund":                true,
+	"fixed_deposit_maturity":     true,
+	"recurring_deposit_maturity": true,
+	"transfer_in":                true,
+}
+
+func signedAmount(t models.Transaction) float64 {
+	if creditTypes[t.Type] {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+// postCredit adds amount to a locked account and records the transaction. Callers check the
+// account status first.
+func postCredit(tx *gorm.DB, account *models.Account, txType string, amount float64, description string) (*models.Transaction, error) {
+	account.Balance += amount
+	return post(tx, account, txType, amount, description)
+}
+
+// postDebit takes amount from a locked account and records the transaction.
+func postDebit(tx *gorm.DB, account *models.Account, txType string, amount float64, description string) (*models.Transaction, error) {
+	if account.Balance < amount {
+		return nil, InsufficientFundsError("insufficient_balance", "insufficient balance")
+	}
+	account.Balance -= amount
+	return post(tx, account, txType, amount, description)
+}
+
+func post(tx *gorm.DB, account *models.Account, txType string, amount float64, description string) (*models.Transaction, error) {
+	account.Version++
+	if err := tx.Model(account).Updates(map[string]interface{}{"balance": account.Balance, "version": account.Version}).Error; err != nil {
+		return nil, err
+	}
+
+	record := models.Transaction{
+		AccountID:   account.ID,
+		Type:        txType,
+		Amount:      amount,
+		Description: description,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// emi is the fixed monthly installment repaying principal over months at an annual percentage rate.
+func emi(principal, annualRate float64, months int) float64 {
+	if months <= 0 {
+		return principal
+	}
+	r := annualRate / 12 / 100
+	if r == 0 {
+		return principal / float64(months)
+	}
+	f := math.Pow(1+r, float64(months))
+	return principal * r * f / (f - 1)
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the limits a withdrawal can trip, returned as the error's Limit
+const (
+	LimitSingleWithdrawal       = "max_single_withdrawal"
+	LimitDailyWithdrawalTotal   = "daily_withdrawal_total"
+	LimitMonthlyWithdrawalTotal = "monthly_withdrawal_total"
+	LimitDailyWithdrawalCount   = "daily_withdrawal_count"
+)
+
+// transaction types that count against withdrawal limits
+var limitedTypes = []string{"withdrawal", "transfer_out"}
+
+// WithdrawalUsage is what an account has withdrawn so far in the current day and month.
+type WithdrawalUsage struct {
+	WithdrawnToday     float64 `json:"withdrawn_today"`
+	WithdrawalsToday   int64   `json:"withdrawals_today"`
+	WithdrawnThisMonth float64 `json:"withdrawn_this_month"`
+}
+
+// effectiveLimits merges the account's own limits over those of its type.
+func effectiveLimits(db *gorm.DB, account *models.Account) (models.WithdrawalLimits, error) {
+	var rows []models.TransactionLimit
+	if err := db.Where("account_id = ? OR account_type = ?", account.ID, account.AccountType).Find(&rows).Error; err != nil {
+		return models.WithdrawalLimits{}, err
+	}
+	var byType, own models.WithdrawalLimits
+	for _, row := range rows {
+		if row.AccountID != nil {
+			own = row.WithdrawalLimits
+		} else {
+			byType = row.WithdrawalLimits
+		}
+	}
+
+	limits := byType
+	if own.MaxSingleWithdrawal != nil {
+		limits.MaxSingleWithdrawal = own.MaxSingleWithdrawal
+	}
+	if own.DailyWithdrawalTotal != nil {
+		limits.DailyWithdrawalTotal = own.DailyWithdrawalTotal
+	}
+	if own.MonthlyWithdrawalTotal != nil {
+		limits.MonthlyWithdrawalTotal = own.MonthlyWithdrawalTotal
+	}
+	if own.DailyWithdrawalCount != nil {
+		limits.DailyWithdrawalCount = own.DailyWithdrawalCount
+	}
+	return limits, nil
+}
+
+func withdrawalUsage(db *gorm.DB, accountID uint, now time.Time) (WithdrawalUsage, error) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var usage WithdrawalUsage
+	var today struct {
+		Total float64
+		Count int64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("account_id = ? AND type IN ? AND transaction_date >= ?", accountID, limitedTypes, dayStart).
+		Scan(&today).Error; err != nil {
+		return usage, err
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("account_id = ? AND type IN ? AND transaction_date >= ?", accountID, limitedTypes, monthStart).
+		Scan(&usage.WithdrawnThisMonth).Error; err != nil {
+		return usage, err
+	}
+	usage.WithdrawnToday = today.Total
+	usage.WithdrawalsToday = today.Count
+	return usage, nil
+}
+
+// checkLimits rejects a withdrawal of amount that would break one of the account's limits. Run it
+// with the account locked so concurrent withdrawals can't both squeeze under a limit.
+func checkLimits(db *gorm.DB, account *models.Account, amount float64, now time.Time) error {
+	limits, err := effectiveLimits(db, account)
+	if err != nil {
+		return err
+	}
+	if limits.MaxSingleWithdrawal != nil && amount > *limits.MaxSingleWithdrawal {
+		return LimitExceededError(LimitSingleWithdrawal, fmt.Sprintf("amount exceeds the single withdrawal limit of %.2f", *limits.MaxSingleWithdrawal))
+	}
+	if limits.DailyWithdrawalTotal == nil && limits.MonthlyWithdrawalTotal == nil && limits.DailyWithdrawalCount == nil {
+		return nil
+	}
+
+	usage, err := withdrawalUsage(db, account.ID, now)
+	if err != nil {
+		return err
+	}
+	if limits.DailyWithdrawalCount != nil && usage.WithdrawalsToday+1 > int64(*limits.DailyWithdrawalCount) {
+		return LimitExceededError(LimitDailyWithdrawalCount, fmt.Sprintf("daily limit of %d withdrawals reached", *limits.DailyWithdrawalCount))
+	}
+	if limits.DailyWithdrawalTotal != nil && roundMoney(usage.WithdrawnToday+amount) > *limits.DailyWithdrawalTotal {
+		return LimitExceededError(LimitDailyWithdrawalTotal, fmt.Sprintf("amount exceeds the daily withdrawal limit of %.2f, %.2f already withdrawn today", *limits.DailyWithdrawalTotal, usage.WithdrawnToday))
+	}
+	if limits.MonthlyWithdrawalTotal != nil && roundMoney(usage.WithdrawnThisMonth+amount) > *limits.MonthlyWithdrawalTotal {
+		return LimitExceededError(LimitMonthlyWithdrawalTotal, fmt.Sprintf("amount exceeds the monthly withdrawal limit of %.2f, %.2f already withdrawn this month", *limits.MonthlyWithdrawalTotal, usage.WithdrawnThisMonth))
+	}
+	return nil
+}
+
+type LimitService struct {
+	db        *gorm.DB
+	approvals *ApprovalService
+}
+
+// NewLimitService registers limit changes with the approval service, every change needs a
+// second person unless there is no approval service.
+func NewLimitService(db *gorm.DB, approvals *ApprovalService) *LimitService {
+	s := &LimitService{db: db, approvals: approvals}
+	if approvals != nil {
+		approvals.Register(OpLimitChange, s.executeLimitChange)
+	}
+	return s
+}
+
+// AccountLimits describes the limits applying to one account and how much of them is used.
+type AccountLimits struct {
+	AccountType *models.TransactionLimit `json:"account_type_limits"`
+	Account     *models.TransactionLimit `json:"account_limits"`
+	Effective   models.WithdrawalLimits  `json:"effective"`
+	Usage       WithdrawalUsage          `json:"usage"`
+}
+
+func (s *LimitService) GetForAccount(ctx context.Context, accountID uint) (*AccountLimits, error) {
+	db := s.db.WithContext(ctx)
+	var account models.Account
+	if err := db.First(&account, accountID).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+
+	var result AccountLimits
+	var err error
+	if result.AccountType, err = findLimit(db, "account_type = ?", account.AccountType); err != nil {
+		return nil, err
+	}
+	if result.Account, err = findLimit(db, "account_id = ?", account.ID); err != nil {
+		return nil, err
+	}
+	if result.Effective, err = effectiveLimits(db, &account); err != nil {
+		return nil, err
+	}
+	if result.Usage, err = withdrawalUsage(db, account.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *LimitService) GetForAccountType(ctx context.Context, accountType string) (*models.TransactionLimit, error) {
+	limit, err := findLimit(s.db.WithContext(ctx), "account_type = ?", accountType)
+	if err != nil {
+		return nil, err
+	}
+	if limit == nil {
+		return nil, NotFoundError("transaction_limit_not_found", "no limits are set for account type "+accountType)
+	}
+	return limit, nil
+}
+
+func findLimit(db *gorm.DB, query string, arg interface{}) (*models.TransactionLimit, error) {
+	var limits []models.TransactionLimit
+	if err := db.Where(query, arg).Limit(1).Find(&limits).Error; err != nil {
+		return nil, err
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	return &limits[0], nil
+}
+
+type limitChangePayload struct {
+	AccountID   *uint                   `json:"account_id,omitempty"`
+	AccountType *string                 `json:"account_type,omitempty"`
+	Limits      models.WithdrawalLimits `json:"limits"`
+}
+
+// SetForAccount replaces the account's own limits, nil fields fall back to the account type.
+func (s *LimitService) SetForAccount(ctx context.Context, accountID uint, limits models.WithdrawalLimits) (*models.TransactionLimit, error) {
+	var account models.Account
+	if err := s.db.WithContext(ctx).First(&account, accountID).Error; err != nil {
+		return nil, dbError(err, "account")
+	}
+	return s.set(ctx, limitChangePayload{AccountID: &accountID, Limits: limits})
+}
+
+// SetForAccountType replaces the limits of every account of the type, nil fields are unlimited.
+func (s *LimitService) SetForAccountType(ctx context.Context, accountType string, limits models.WithdrawalLimits) (*models.TransactionLimit, error) {
+	return s.set(ctx, limitChangePayload{AccountType: &accountType, Limits: limits})
+}
+
+func (s *LimitService) set(ctx context.Context, change limitChangePayload) (*models.TransactionLimit, error) {
+	if s.approvals.requiresApproval(ctx, OpLimitChange, 0) {
+		resourceType, resourceID := "account_type", uint(0)
+		if change.AccountID != nil {
+			resourceType, resourceID = "account", *change.AccountID
+		}
+		return nil, s.approvals.submit(ctx, OpLimitChange, resourceType, resourceID, 0, change)
+	}
+
+	var limit *models.TransactionLimit
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []models.TransactionLimit
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		if change.AccountID != nil {
+			query = query.Where("account_id = ?", *change.AccountID)
+		} else {
+			query = query.Where("account_type = ?", *change.AccountType)
+		}
+		if err := query.Find(&existing).Error; err != nil {
+			return err
+		}
+
+		if len(existing) == 0 {
+			limit = &models.TransactionLimit{AccountID: change.AccountID, AccountType: change.AccountType, WithdrawalLimits: change.Limits}
+			return dbError(tx.Create(limit).Error, "transaction_limit")
+		}
+		limit = &existing[0]
+		limit.WithdrawalLimits = change.Limits
+		limit.Version++
+		//nil fields have to be written too, they clear the limit
+		return tx.Model(limit).Updates(map[string]interface{}{
+			"max_single_withdrawal":    limit.MaxSingleWithdrawal,
+			"daily_withdrawal_total":   limit.DailyWithdrawalTotal,
+			"monthly_withdrawal_total": limit.MonthlyWithdrawalTotal,
+			"daily_withdrawal_count":   limit.DailyWithdrawalCount,
+			"version":                  limit.Version,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("transaction limits changed", "limit_id", limit.ID, "account_id", change.AccountID, "account_type", change.AccountType)
+	return limit, nil
+}
+
+func (s *LimitService) executeLimitChange(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p limitChangePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	limits := *s
+	limits.db = tx
+	return limits.set(ctx, p)
+}
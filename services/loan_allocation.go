@@ -0,0 +1,105 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+// what Repay does with money left once the schedule is covered
+const (
+	OverpaymentRefund  = "refund"
+	OverpaymentAdvance = "advance"
+)
+
+// latePenalty is the late fee an installment carries at the given time. It is charged once, on
+// what was still unpaid of the installment when it was first found overdue.
+func latePenalty(product *models.LoanProduct, installment *models.LoanInstallment, at time.Time) float64 {
+	if installment.Penalty > 0 || product.LatePaymentFeePercent == 0 || !installment.DueDate.Before(at) {
+		return installment.Penalty
+	}
+	unpaid := installment.Amount - installment.PrincipalPaid - installment.InterestPaid
+	return roundMoney(unpaid * product.LatePaymentFeePercent / 100)
+}
+
+// assessPenalties charges the late fee on pending installments overdue at the given time.
+func assessPenalties(tx *gorm.DB, product *models.LoanProduct, installments []models.LoanInstallment, at time.Time) error {
+	for i := range installments {
+		installment := &installments[i]
+		if installment.Penalty > 0 {
+			continue
+		}
+		penalty := latePenalty(product, installment, at)
+		if penalty == 0 {
+			continue
+		}
+		installment.Penalty = penalty
+		if err := tx.Model(installment).Update("penalty", penalty).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocate runs amount down the pending installments: late fees first, then interest and
+// principal of each installment, oldest first. Installments are updated in place and saved, the
+// allocations are returned for the caller to attach to the repayment together with what is left.
+func allocate(tx *gorm.DB, installments []models.LoanInstallment, amount float64, paidAt time.Time) ([]models.RepaymentAllocation, float64, error) {
+	var allocations []models.RepaymentAllocation
+	left := roundMoney(amount)
+	take := func(installment *models.LoanInstallment, component string, owed float64, paid *float64) {
+		part := roundMoney(math.Min(left, owed-*paid))
+		if part <= 0 {
+			return
+		}
+		*paid = roundMoney(*paid + part)
+		left = roundMoney(left - part)
+		id := installment.ID
+		allocations = append(allocations, models.RepaymentAllocation{InstallmentID: &id, Component: component, Amount: part})
+	}
+
+	for i := range installments {
+		take(&installments[i], models.AllocationPenalty, installments[i].Penalty, &installments[i].PenaltyPaid)
+	}
+	for i := range installments {
+		take(&installments[i], models.AllocationInterest, installments[i].Interest, &installments[i].InterestPaid)
+		take(&installments[i], models.AllocationPrincipal, installments[i].Principal, &installments[i].PrincipalPaid)
+	}
+
+	for i := range installments {
+		installment := &installments[i]
+		changes := map[string]interface{}{
+			"penalty_paid":   installment.PenaltyPaid,
+			"interest_paid":  installment.InterestPaid,
+			"principal_paid": installment.PrincipalPaid,
+		}
+		if installment.PenaltyPaid >= installment.Penalty && installment.InterestPaid >= installment.Interest && installment.PrincipalPaid >= installment.Principal {
+			installment.Status = models.InstallmentPaid
+			installment.PaidAt = &paidAt
+			changes["status"] = installment.Status
+			changes["paid_at"] = paidAt
+		}
+		if err := tx.Model(installment).Updates(changes).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	return allocations, left, nil
+}
+
+// saveAllocations attaches the allocations to a stored repayment.
+func saveAllocations(tx *gorm.DB, repayment *models.Repayment, allocations []models.RepaymentAllocation) error {
+	if len(allocations) == 0 {
+		return nil
+	}
+	for i := range allocations {
+		allocations[i].RepaymentID = repayment.ID
+	}
+	if err := tx.Create(&allocations).Error; err != nil {
+		return err
+	}
+	repayment.Allocations = allocations
+	return nil
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/metrics"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UnderwritingPolicy struct {
+	// MaxDebtToIncome caps all monthly installments, the new one included, as a share of income
+	MaxDebtToIncome float64
+	// MaxExposureMultiple caps outstanding loans plus the request as a multiple of monthly income
+	MaxExposureMultiple float64
+	// BalanceLookbackDays is the window the average daily balance is taken over
+	BalanceLookbackDays int
+	// OfficerRoles may approve or reject applications
+	OfficerRoles []string
+	// ValuationMaxAgeDays is how old a collateral valuation may be to count towards loan-to-value
+	ValuationMaxAgeDays int
+}
+
+type LoanApplicationService struct {
+	db        *gorm.DB
+	loans     *LoanService
+	customers *CustomerService
+	approvals *ApprovalService
+	policy    UnderwritingPolicy
+}
+
+func NewLoanApplicationService(db *gorm.DB, loans *LoanService, customers *CustomerService, approvals *ApprovalService, policy UnderwritingPolicy) *LoanApplicationService {
+	s := &LoanApplicationService{db: db, loans: loans, customers: customers, approvals: approvals, policy: policy}
+	if approvals != nil {
+		approvals.Register(OpLoanApproval, s.executeApproval)
+		approvals.OnReject(OpLoanApproval, s.rejectApproval)
+	}
+	return s
+}
+
+// Apply records the application with its eligibility evaluated against the customer's current
+// loans and account history.
+func (s *LoanApplicationService) Apply(ctx context.Context, application *models.LoanApplication) error {
+	db := s.db.WithContext(ctx)
+	if err := ensureExists(db, &models.Customer{}, application.CustomerID, "customer_id", "customer"); err != nil {
+		return err
+	}
+	if err := ensureExists(db, &models.Account{}, application.AccountID, "account_id", "account"); err != nil {
+		return err
+	}
+	product, err := activeProduct(db, application.ProductID)
+	if err != nil {
+		return err
+	}
+	if err := checkProductTerms(product, application.RequestedAmount, application.TermMonths, "requested_amount"); err != nil {
+		return err
+	}
+
+	var holds int64
+	if err := db.Model(&models.AccountCustomer{}).
+		Where("account_id = ? AND customer_id = ? AND role IN ?", application.AccountID, application.CustomerID, []string{models.HolderRolePrimary, models.HolderRoleJoint}).
+		Count(&holds).Error; err != nil {
+		return err
+	}
+	if holds == 0 {
+		return FieldValidationError(FieldError{Field: "account_id", Message: "customer must hold the account the loan is paid into"})
+	}
+
+	//the quoted rate is indicative, the loan is priced again when it is booked
+	if application.InterestRate, err = productRate(db, product); err != nil {
+		return err
+	}
+	application.Status = models.LoanApplicationSubmitted
+	if err := s.evaluate(ctx, application); err != nil {
+		return err
+	}
+	if err := db.Create(application).Error; err != nil {
+		return dbError(err, "loan_application")
+	}
+
+	logging.FromContext(ctx).Info("loan application submitted", "application_id", application.ID, "customer_id", application.CustomerID, "eligible", application.Eligible)
+	return nil
+}
+
+func (s *LoanApplicationService) GetByID(ctx context.Context, id uint) (*models.LoanApplication, error) {
+	var application models.LoanApplication
+	if err := s.db.WithContext(ctx).First(&application, id).Error; err != nil {
+		return nil, dbError(err, "loan_application")
+	}
+	return &application, nil
+}
+
+func (s *LoanApplicationService) GetAll(ctx context.Context, status string, customerID uint) ([]models.LoanApplication, error) {
+	query := s.db.WithContext(ctx).Order("id desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if customerID != 0 {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	var applications []models.LoanApplication
+	if err := query.Find(&applications).Error; err != nil {
+		return nil, err
+	}
+	return applications, nil
+}
+
+// Approve re-evaluates the application and books the loan. Approving an ineligible application
+// is an override and needs a note; amounts above the approval threshold wait for a checker.
+func (s *LoanApplicationService) Approve(ctx context.Context, id uint, note string) (*models.LoanApplication, error) {
+	officer, err := s.officer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	application, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if application.Status != models.LoanApplicationSubmitted {
+		return nil, ConflictError("loan_application_not_submitted", "loan application is already "+application.Status)
+	}
+
+	if err := s.evaluate(ctx, application); err != nil {
+		return nil, err
+	}
+	//loan-to-value is a hard limit, unlike eligibility it can't be overridden
+	ltvErr := s.checkLoanToValue(s.db.WithContext(ctx), application)
+	if err := s.saveEvaluation(ctx, application); err != nil {
+		return nil, err
+	}
+	if ltvErr != nil {
+		return nil, ltvErr
+	}
+	if !application.Eligible && strings.TrimSpace(note) == "" {
+		return nil, ValidationError("override_note_required", "application is not eligible ("+application.EligibilityNotes+"), approving it needs a note")
+	}
+
+	if s.approvals.requiresApproval(ctx, OpLoanApproval, application.RequestedAmount) {
+		//the application only waits if the request is stored, and only one approval can park it
+		var request *models.ApprovalRequest
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.LoanApplication{}).
+				Where("id = ? AND status = ?", id, models.LoanApplicationSubmitted).
+				Updates(map[string]interface{}{
+					"status":        models.LoanApplicationAwaitingApproval,
+					"officer_id":    officer.ID,
+					"decision_note": note,
+					"version":       gorm.Expr("version + 1"),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ConflictError("loan_application_not_submitted", "loan application is no longer submitted")
+			}
+			var err error
+			request, err = s.approvals.park(ctx, tx, OpLoanApproval, "loan_application", id, application.RequestedAmount, loanApprovalPayload{
+				ApplicationID: id,
+				OfficerID:     officer.ID,
+				Note:          note,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, pending(ctx, request)
+	}
+
+	return s.disburse(ctx, id, officer.ID, note)
+}
+
+func (s *LoanApplicationService) Reject(ctx context.Context, id uint, note string) (*models.LoanApplication, error) {
+	officer, err := s.officer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return rejectApplication(tx, id, officer.ID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("loan application rejected", "application_id", id, "officer", officer.ID)
+	return s.GetByID(ctx, id)
+}
+
+// rejectApplication rejects an application that is still open and hands back the security
+// offered on it.
+func rejectApplication(tx *gorm.DB, id uint, officerID, note string) error {
+	now := time.Now()
+	result := tx.Model(&models.LoanApplication{}).
+		Where("id = ? AND status IN ?", id, []string{models.LoanApplicationSubmitted, models.LoanApplicationAwaitingApproval}).
+		Updates(map[string]interface{}{
+			"status":        models.LoanApplicationRejected,
+			"officer_id":    officerID,
+			"decision_note": note,
+			"decided_at":    now,
+			"version":       gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var application models.LoanApplication
+		if err := tx.First(&application, id).Error; err != nil {
+			return dbError(err, "loan_application")
+		}
+		return ConflictError("loan_application_not_submitted", "loan application is already "+application.Status)
+	}
+
+	if err := tx.Model(&models.Collateral{}).
+		Where("application_id = ? AND lien_status = ?", id, models.LienPending).
+		Updates(map[string]interface{}{"lien_status": models.LienReleased, "version": gorm.Expr("version + 1")}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.LoanGuarantor{}).
+		Where("application_id = ? AND status = ?", id, models.GuaranteePending).
+		Updates(map[string]interface{}{"status": models.GuaranteeReleased, "released_at": now, "version": gorm.Expr("version + 1")}).Error
+}
+
+type loanApprovalPayload struct {
+	ApplicationID uint   `json:"application_id"`
+	OfficerID     string `json:"officer_id"`
+	Note          string `json:"note"`
+}
+
+func (s *LoanApplicationService) executeApproval(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p loanApprovalPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	applications := *s
+	applications.db = tx
+	return applications.disburse(ctx, p.ApplicationID, p.OfficerID, p.Note)
+}
+
+// rejectApproval closes the application when the checker turns the approval down, otherwise
+// it would wait for approval forever.
+func (s *LoanApplicationService) rejectApproval(ctx context.Context, tx *gorm.DB, request *models.ApprovalRequest) error {
+	var p loanApprovalPayload
+	if err := json.Unmarshal(request.Payload, &p); err != nil {
+		return err
+	}
+	if err := rejectApplication(tx, p.ApplicationID, request.CheckerID, request.DecisionNote); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("loan application rejected", "application_id", p.ApplicationID, "checker", request.CheckerID)
+	return nil
+}
+
+// disburse turns the application into a loan and pays the amount into the borrower's account.
+func (s *LoanApplicationService) disburse(ctx context.Context, id uint, officerID, note string) (*models.LoanApplication, error) {
+	var loan models.Loan
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var application models.LoanApplication
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, id).Error; err != nil {
+			return dbError(err, "loan_application")
+		}
+		if application.Status != models.LoanApplicationSubmitted && application.Status != models.LoanApplicationAwaitingApproval {
+			return ConflictError("loan_application_not_submitted", "loan application is already "+application.Status)
+		}
+		if err := s.checkLoanToValue(tx, &application); err != nil {
+			return err
+		}
+
+		account, err := lockAccount(tx, application.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanCredit(tx, account); err != nil {
+			return err
+		}
+
+		loan = models.Loan{
+			AccountID:     application.AccountID,
+			CustomerID:    application.CustomerID,
+			ProductID:     application.ProductID,
+			Amount:        application.RequestedAmount,
+			TermMonths:    application.TermMonths,
+			ApplicationID: &application.ID,
+		}
+		if err := s.loans.create(tx, &loan); err != nil {
+			return err
+		}
+		if _, err := postCredit(tx, account, "loan_disbursement", loan.Amount, fmt.Sprintf("disbursement of loan %d", loan.ID)); err != nil {
+			return err
+		}
+		if loan.ProcessingFee > 0 {
+			if _, err := postDebit(tx, account, "processing_fee", loan.ProcessingFee, fmt.Sprintf("processing fee for loan %d", loan.ID)); err != nil {
+				return err
+			}
+		}
+
+		//the security offered on the application now backs the loan
+		if err := tx.Model(&models.Collateral{}).
+			Where("application_id = ? AND lien_status = ?", application.ID, models.LienPending).
+			Updates(map[string]interface{}{"loan_id": loan.ID, "lien_status": models.LienMarked, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LoanGuarantor{}).
+			Where("application_id = ? AND status = ?", application.ID, models.GuaranteePending).
+			Updates(map[string]interface{}{"loan_id": loan.ID, "status": models.GuaranteeActive, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&application).Updates(map[string]interface{}{
+			"status":        models.LoanApplicationApproved,
+			"officer_id":    officerID,
+			"decision_note": note,
+			"decided_at":    time.Now(),
+			"loan_to_value": application.LoanToValue,
+			"loan_id":       loan.ID,
+			"version":       gorm.Expr("version + 1"),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.LoansCreated.Inc()
+	logging.FromContext(ctx).Info("loan application approved", "application_id", id, "loan_id", loan.ID, "officer", officerID, "amount", loan.Amount)
+	return s.GetByID(ctx, id)
+}
+
+// evaluate fills in the eligibility snapshot: outstanding exposure and installments on ongoing
+// loans plus what the customer has guaranteed, the average daily balance over the lookback window,
+// and the resulting debt-to-income.
+func (s *LoanApplicationService) evaluate(ctx context.Context, application *models.LoanApplication) error {
+	loans, err := s.customers.GetLoans(ctx, application.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	exposure, obligations := 0.0, 0.0
+	for _, loan := range loans {
+		if loan.Status == "closed" {
+			continue
+		}
+		outstanding, err := principalOutstanding(s.db.WithContext(ctx), loan.ID)
+		if err != nil {
+			return err
+		}
+		exposure += outstanding
+		var next models.LoanInstallment
+		if err := s.db.WithContext(ctx).
+			Where("loan_id = ? AND status = ?", loan.ID, models.InstallmentPending).
+			Order("number asc").Limit(1).Find(&next).Error; err != nil {
+			return err
+		}
+		obligations += next.Amount
+	}
+	_, guaranteed, err := guarantees(s.db.WithContext(ctx), application.CustomerID)
+	if err != nil {
+		return err
+	}
+	exposure += guaranteed
+
+	averageBalance, err := s.averageBalance(ctx, application.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	proposed := emi(application.RequestedAmount, application.InterestRate, application.TermMonths)
+	dti := 0.0
+	if application.MonthlyIncome > 0 {
+		dti = (obligations + proposed) / application.MonthlyIncome
+	}
+
+	var reasons []string
+	if application.MonthlyIncome <= 0 {
+		reasons = append(reasons, "no monthly income declared")
+	} else {
+		if dti > s.policy.MaxDebtToIncome {
+			reasons = append(reasons, fmt.Sprintf("debt-to-income %.2f exceeds %.2f", dti, s.policy.MaxDebtToIncome))
+		}
+		if limit := s.policy.MaxExposureMultiple * application.MonthlyIncome; exposure+application.RequestedAmount > limit {
+			reasons = append(reasons, fmt.Sprintf("total exposure %.2f exceeds %.2f", exposure+application.RequestedAmount, limit))
+		}
+	}
+	if averageBalance < proposed {
+		reasons = append(reasons, fmt.Sprintf("average balance %.2f is below one installment of %.2f", averageBalance, proposed))
+	}
+
+	application.ExistingExposure = roundMoney(exposure)
+	application.MonthlyObligations = roundMoney(obligations)
+	application.AverageBalance = roundMoney(averageBalance)
+	application.ProposedEMI = roundMoney(proposed)
+	application.DebtToIncome = dti
+	application.Eligible = len(reasons) == 0
+	application.EligibilityNotes = truncate(strings.Join(reasons, "; "), 500)
+	application.EvaluatedAt = time.Now()
+	return nil
+}
+
+func (s *LoanApplicationService) saveEvaluation(ctx context.Context, application *models.LoanApplication) error {
+	return s.db.WithContext(ctx).Model(application).Select(
+		"existing_exposure", "average_balance", "monthly_obligations", "proposed_emi",
+		"debt_to_income", "eligible", "eligibility_notes", "evaluated_at", "loan_to_value",
+	).Updates(application).Error
+}
+
+// checkLoanToValue holds secured products to their loan-to-value limit against the collateral
+// pledged on the application and records the ratio. Valuations older than the policy allows
+// don't count until the asset is pledged again with a fresh one.
+func (s *LoanApplicationService) checkLoanToValue(db *gorm.DB, application *models.LoanApplication) error {
+	var product models.LoanProduct
+	if err := db.First(&product, application.ProductID).Error; err != nil {
+		return dbError(err, "loan_product")
+	}
+	if product.MaxLTVPercent <= 0 {
+		return nil
+	}
+
+	var pledged []models.Collateral
+	if err := db.Where("application_id = ? AND lien_status <> ?", application.ID, models.LienReleased).Find(&pledged).Error; err != nil {
+		return err
+	}
+	oldest := time.Now().AddDate(0, 0, -s.policy.ValuationMaxAgeDays)
+	total, stale := 0.0, 0
+	for _, collateral := range pledged {
+		if s.policy.ValuationMaxAgeDays > 0 && collateral.ValuationDate.Before(oldest) {
+			stale++
+			continue
+		}
+		total += collateral.Valuation
+	}
+	if total <= 0 {
+		if stale > 0 {
+			return ConflictError("collateral_valuation_stale", fmt.Sprintf("collateral valuations older than %d days don't count, pledge it again with a fresh valuation", s.policy.ValuationMaxAgeDays))
+		}
+		return ConflictError("collateral_required", product.Code+" loans are secured, pledge collateral before approval")
+	}
+
+	ltv := roundMoney(application.RequestedAmount / total * 100)
+	application.LoanToValue = &ltv
+	if ltv > product.MaxLTVPercent {
+		return ConflictError("loan_to_value_exceeded", fmt.Sprintf("loan-to-value %.2f%% exceeds %.2f%% for %s", ltv, product.MaxLTVPercent, product.Code))
+	}
+	return nil
+}
+
+// averageBalance sums, over the customer's accounts, the mean end-of-day balance of the lookback
+// window. Daily balances are rebuilt backwards from today's balance and the transactions since.
+func (s *LoanApplicationService) averageBalance(ctx context.Context, customerID uint) (float64, error) {
+	accounts, err := s.customers.GetAccounts(ctx, customerID)
+	if err != nil {
+		return 0, err
+	}
+
+	days := s.policy.BalanceLookbackDays
+	if days <= 0 {
+		days = 90
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := today.AddDate(0, 0, -days+1)
+
+	total := 0.0
+	for _, account := range accounts {
+		var txs []models.Transaction
+		if err := s.db.WithContext(ctx).
+			Where("account_id = ? AND transaction_date >= ?", account.ID, since).
+			Order("transaction_date desc").Find(&txs).Error; err != nil {
+			return 0, err
+		}
+
+		balance, i, sum := account.Balance, 0, 0.0
+		for day := 0; day < days; day++ {
+			dayEnd := today.AddDate(0, 0, 1-day)
+			for i < len(txs) && !txs[i].CreatedAt.Before(dayEnd) {
+				balance -= signedAmount(txs[i])
+				i++
+			}
+			sum += balance
+		}
+		total += sum / float64(days)
+	}
+	return total, nil
+}
+
+func (s *LoanApplicationService) officer(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return actor, ForbiddenError("actor_required", "X-User-ID is required to decide on loan applications")
+	}
+	for _, role := range s.policy.OfficerRoles {
+		if role == actor.Role {
+			return actor, nil
+		}
+	}
+	return actor, ForbiddenError("not_a_loan_officer", "role "+actor.Role+" cannot decide on loan applications")
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+type LoanProductService struct {
+	db *gorm.DB
+}
+
+func NewLoanProductService(db *gorm.DB) *LoanProductService {
+	return &LoanProductService{db: db}
+}
+
+func (s *LoanProductService) Create(ctx context.Context, product *models.LoanProduct) error {
+	if product.BenchmarkID != nil {
+		if err := ensureExists(s.db.WithContext(ctx), &models.BenchmarkRate{}, *product.BenchmarkID, "benchmark_id", "benchmark rate"); err != nil {
+			return err
+		}
+	}
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	return dbError(s.db.WithContext(ctx).Create(product).Error, "loan_product")
+}
+
+func (s *LoanProductService) GetByID(ctx context.Context, id uint) (*models.LoanProduct, error) {
+	var product models.LoanProduct
+	if err := s.db.WithContext(ctx).First(&product, id).Error; err != nil {
+		return nil, dbError(err, "loan_product")
+	}
+	return &product, nil
+}
+
+func (s *LoanProductService) GetAll(ctx context.Context, activeOnly bool) ([]models.LoanProduct, error) {
+	query := s.db.WithContext(ctx).Order("id asc")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	var products []models.LoanProduct
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// Update applies the changes and re-validates the resulting product before committing, so bounds
+// can't end up crossed (e.g. min_amount raised above max_amount).
+func (s *LoanProductService) Update(ctx context.Context, id, version uint, changes map[string]interface{}) (*models.LoanProduct, error) {
+	var product models.LoanProduct
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := applyChanges(tx, &models.LoanProduct{}, id, version, changes, "loan_product"); err != nil {
+			return err
+		}
+		if err := tx.First(&product, id).Error; err != nil {
+			return err
+		}
+		return validateProduct(&product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (s *LoanProductService) Delete(ctx context.Context, id, version uint) error {
+	return deleteVersioned(s.db.WithContext(ctx), &models.LoanProduct{}, id, version, "loan_product")
+}
+
+func validateProduct(product *models.LoanProduct) error {
+	var fields []FieldError
+	if product.MinAmount > product.MaxAmount {
+		fields = append(fields, FieldError{Field: "min_amount", Message: "must not exceed max_amount"})
+	}
+	if product.MinTermMonths > product.MaxTermMonths {
+		fields = append(fields, FieldError{Field: "min_term_months", Message: "must not exceed max_term_months"})
+	}
+	switch product.RateType {
+	case models.RateTypeFixed:
+		if product.FixedRate <= 0 {
+			fields = append(fields, FieldError{Field: "fixed_rate", Message: "is required for fixed rate products"})
+		}
+	case models.RateTypeFloating:
+		if product.BenchmarkID == nil {
+			fields = append(fields, FieldError{Field: "benchmark_id", Message: "is required for floating rate products"})
+		}
+	}
+	if len(fields) > 0 {
+		return FieldValidationError(fields...)
+	}
+	return nil
+}
+
+// checkProductTerms reports which of amount and term fall outside the product's bounds.
+func checkProductTerms(product *models.LoanProduct, amount float64, termMonths int, amountField string) error {
+	var fields []FieldError
+	if amount < product.MinAmount || amount > product.MaxAmount {
+		fields = append(fields, FieldError{Field: amountField, Message: fmt.Sprintf("must be between %.2f and %.2f for %s", product.MinAmount, product.MaxAmount, product.Code)})
+	}
+	if termMonths < product.MinTermMonths || termMonths > product.MaxTermMonths {
+		fields = append(fields, FieldError{Field: "term_months", Message: fmt.Sprintf("must be between %d and %d for %s", product.MinTermMonths, product.MaxTermMonths, product.Code)})
+	}
+	if len(fields) > 0 {
+		return FieldValidationError(fields...)
+	}
+	return nil
+}
+
+// productRate is the rate a new loan under the product gets today.
+func productRate(db *gorm.DB, product *models.LoanProduct) (float64, error) {
+	if product.RateType != models.RateTypeFloating {
+		return product.FixedRate, nil
+	}
+	var benchmark models.BenchmarkRate
+	if err := db.First(&benchmark, *product.BenchmarkID).Error; err != nil {
+		return 0, dbError(err, "benchmark_rate")
+	}
+	return benchmark.Rate + product.Spread, nil
+}
+
+// processingFee is charged once at disbursement.
+func processingFee(product *models.LoanProduct, amount float64) float64 {
+	fee := amount * product.ProcessingFeePercent / 100
+	if fee < product.MinProcessingFee {
+		fee = product.MinProcessingFee
+	}
+	return roundMoney(fee)
+}
+
+// activeProduct loads a product a new loan may still be sold under.
+func activeProduct(db *gorm.DB, id uint) (*models.LoanProduct, error) {
+	if err := ensureExists(db, &models.LoanProduct{}, id, "product_id", "loan product"); err != nil {
+		return nil, err
+	}
+	var product models.LoanProduct
+	if err := db.First(&product, id).Error; err != nil {
+		return nil, err
+	}
+	if !product.Active {
+		return nil, FieldValidationError(FieldError{Field: "product_id", Message: "loan product is no longer offered"})
+	}
+	return &product, nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Restructuring is what changes when a loan is rescheduled for hardship. TermMonths is the number
+// of installments after the moratorium and defaults to the installments left.
+type Restructuring struct {
+	TermMonths       *int
+	InterestRate     *float64
+	MoratoriumMonths int
+	Reason           string
+}
+
+// recordTerms stores terms as the loan's next revision at the loan's current rate.
+func recordTerms(tx *gorm.DB, loan *models.Loan, terms *models.LoanTerms) error {
+	loan.TermsRevision++
+	terms.LoanID = loan.ID
+	terms.Revision = loan.TermsRevision
+	terms.InterestRate = loan.InterestRate
+	if terms.EffectiveFrom.IsZero() {
+		terms.EffectiveFrom = time.Now()
+	}
+	return tx.Create(terms).Error
+}
+
+type RestructureResult struct {
+	Loan     models.Loan              `json:"loan"`
+	Terms    models.LoanTerms         `json:"terms"`
+	Schedule []models.LoanInstallment `json:"schedule"`
+}
+
+// Restructure replaces the rest of the schedule. Everything owed today (principal, unpaid and
+// accrued interest and late fees, less any advance) becomes the new principal, interest over the
+// moratorium is capitalized on top, and the new installments start once the moratorium is over.
+// Replaced installments are kept as restructured.
+func (s *LoanService) Restructure(ctx context.Context, id uint, r Restructuring) (*RestructureResult, error) {
+	if r.TermMonths == nil && r.InterestRate == nil && r.MoratoriumMonths == 0 {
+		return nil, ValidationError("nothing_to_restructure", "term_months, interest_rate or moratorium_months is required")
+	}
+	actor, err := s.officer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result RestructureResult
+	now := time.Now()
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		if loan.Status == "closed" {
+			return ConflictError("loan_closed", "loan is already closed")
+		}
+		var product models.LoanProduct
+		if err := tx.First(&product, loan.ProductID).Error; err != nil {
+			return dbError(err, "loan_product")
+		}
+		pending, err := pendingInstallments(tx, loan.ID)
+		if err != nil {
+			return err
+		}
+		if err := assessPenalties(tx, &product, pending, now); err != nil {
+			return err
+		}
+		quote, err := payoffQuote(tx, &loan, now)
+		if err != nil {
+			return err
+		}
+		principal := roundMoney(quote.PrincipalOutstanding + quote.OverdueInterest + quote.AccruedInterest + quote.Penalties - loan.Advance)
+		if principal <= 0 {
+			return ConflictError("nothing_outstanding", "nothing is owed on the loan, repay it to close it")
+		}
+
+		var paid int64
+		if err := tx.Model(&models.LoanInstallment{}).
+			Where("loan_id = ? AND status = ?", loan.ID, models.InstallmentPaid).
+			Count(&paid).Error; err != nil {
+			return err
+		}
+		months := len(pending)
+		if r.TermMonths != nil {
+			months = *r.TermMonths
+		}
+		if err := checkRestructuredTerms(tx, &product, int(paid)+r.MoratoriumMonths+months, r.InterestRate); err != nil {
+			return err
+		}
+
+		//floating loans keep following their benchmark, a new rate moves the spread
+		if r.InterestRate != nil {
+			if loan.RateType == models.RateTypeFloating {
+				loan.Spread += *r.InterestRate - loan.InterestRate
+			}
+			loan.InterestRate = *r.InterestRate
+		}
+		capitalized := roundMoney(principal * (math.Pow(1+loan.InterestRate/12/100, float64(r.MoratoriumMonths)) - 1))
+		principal = roundMoney(principal + capitalized)
+
+		if err := tx.Model(&models.LoanInstallment{}).
+			Where("loan_id = ? AND status = ?", loan.ID, models.InstallmentPending).
+			Update("status", models.InstallmentRestructured).Error; err != nil {
+			return err
+		}
+		var last int
+		if err := tx.Model(&models.LoanInstallment{}).Where("loan_id = ?", loan.ID).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		firstDue := now.AddDate(0, r.MoratoriumMonths+1, 0)
+		installment := roundMoney(emi(principal, loan.InterestRate, months))
+		schedule := buildSchedule(&loan, principal, installment, months, last+1, firstDue)
+		if err := tx.Create(&schedule).Error; err != nil {
+			return err
+		}
+
+		terms := models.LoanTerms{
+			Principal:           principal,
+			TermMonths:          months,
+			MoratoriumMonths:    r.MoratoriumMonths,
+			CapitalizedInterest: capitalized,
+			Installment:         installment,
+			FirstDueDate:        firstDue,
+			Reason:              r.Reason,
+			CreatedBy:           actor.ID,
+			EffectiveFrom:       now,
+		}
+		if err := recordTerms(tx, &loan, &terms); err != nil {
+			return err
+		}
+
+		loan.TermMonths = int(paid) + r.MoratoriumMonths + len(schedule)
+		loan.Advance = 0
+		loan.RestructuredAt = &now
+		loan.Version++
+		if err := tx.Model(&loan).Updates(map[string]interface{}{
+			"loan_interest":   loan.InterestRate,
+			"spread":          loan.Spread,
+			"term_months":     loan.TermMonths,
+			"terms_revision":  loan.TermsRevision,
+			"advance":         loan.Advance,
+			"restructured_at": now,
+			"version":         loan.Version,
+		}).Error; err != nil {
+			return err
+		}
+
+		result = RestructureResult{Loan: loan, Terms: terms, Schedule: schedule}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("loan restructuring failed", "loan_id", id, "error", err)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("loan restructured", "loan_id", id, "revision", result.Terms.Revision, "principal", result.Terms.Principal, "moratorium_months", r.MoratoriumMonths, "by", actor.ID)
+	return &result, nil
+}
+
+func (s *LoanService) GetTerms(ctx context.Context, id uint) ([]models.LoanTerms, error) {
+	if _, err := s.GetByID(ctx, id, true); err != nil {
+		return nil, err
+	}
+	var terms []models.LoanTerms
+	if err := s.db.WithContext(ctx).Where("loan_id = ?", id).Order("revision asc").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// checkRestructuredTerms holds a restructuring to the product the loan was sold under, like
+// origination: the whole tenure has to stay within the product's terms and the rate can't go
+// above what the product charges today.
+func checkRestructuredTerms(tx *gorm.DB, product *models.LoanProduct, tenure int, rate *float64) error {
+	var fields []FieldError
+	if tenure < product.MinTermMonths || tenure > product.MaxTermMonths {
+		fields = append(fields, FieldError{Field: "term_months", Message: fmt.Sprintf("the loan would run %d months, it must be between %d and %d for %s", tenure, product.MinTermMonths, product.MaxTermMonths, product.Code)})
+	}
+	if rate != nil {
+		current, err := productRate(tx, product)
+		if err != nil {
+			return err
+		}
+		if *rate > current {
+			fields = append(fields, FieldError{Field: "interest_rate", Message: fmt.Sprintf("must not exceed the %s rate of %.2f", product.Code, current)})
+		}
+	}
+	if len(fields) > 0 {
+		return FieldValidationError(fields...)
+	}
+	return nil
+}
+
+// officer is the acting employee, who has to hold one of the loan officer roles.
+func (s *LoanService) officer(ctx context.Context) (Actor, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok {
+		return actor, ForbiddenError("actor_required", "X-User-ID is required to restructure loans")
+	}
+	for _, role := range s.officerRoles {
+		if role == actor.Role {
+			return actor, nil
+		}
+	}
+	return actor, ForbiddenError("not_a_loan_officer", "role "+actor.Role+" cannot restructure loans")
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/metrics"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// how a prepayment is absorbed by the remaining schedule
+const (
+	RescheduleReduceTenure = "reduce_tenure"
+	RescheduleReduceEMI    = "reduce_emi"
+)
+
+// buildSchedule amortizes principal in equal monthly installments, numbered from first and the
+// first one due on firstDue. The last installment absorbs rounding so principal adds up.
+func buildSchedule(loan *models.Loan, principal, installment float64, months, first int, firstDue time.Time) []models.LoanInstallment {
+	r := loan.InterestRate / 12 / 100
+	schedule := make([]models.LoanInstallment, 0, months)
+	balance := principal
+	for i := 0; i < months && balance > 0; i++ {
+		interest := roundMoney(balance * r)
+		part := roundMoney(installment - interest)
+		if i == months-1 || part > balance {
+			part = roundMoney(balance)
+		}
+		schedule = append(schedule, models.LoanInstallment{
+			LoanID:           loan.ID,
+			Number:           first + i,
+			DueDate:          firstDue.AddDate(0, i, 0),
+			OpeningPrincipal: roundMoney(balance),
+			Principal:        part,
+			Interest:         interest,
+			Amount:           roundMoney(part + interest),
+			Status:           models.InstallmentPending,
+		})
+		balance = roundMoney(balance - part)
+	}
+	return schedule
+}
+
+// tenureFor is the number of installments of the given size needed to repay principal, 0 when
+// the installment doesn't even cover the first month's interest.
+func tenureFor(principal, annualRate, installment float64) int {
+	r := annualRate / 12 / 100
+	if r == 0 {
+		return int(math.Ceil(principal/installment - 1e-9))
+	}
+	x := 1 - principal*r/installment
+	if x <= 0 {
+		return 0
+	}
+	return int(math.Ceil(-math.Log(x)/math.Log(1+r) - 1e-9))
+}
+
+// generateSchedule writes the initial schedule of a newly booked loan, and the terms it was
+// booked with as revision 1.
+func generateSchedule(tx *gorm.DB, loan *models.Loan) error {
+	installment := roundMoney(emi(loan.Amount, loan.InterestRate, loan.TermMonths))
+	firstDue := loan.StartDate.AddDate(0, 1, 0)
+	schedule := buildSchedule(loan, loan.Amount, installment, loan.TermMonths, 1, firstDue)
+	if err := tx.Create(&schedule).Error; err != nil {
+		return err
+	}
+	terms := models.LoanTerms{
+		LoanID:        loan.ID,
+		Revision:      1,
+		Principal:     loan.Amount,
+		InterestRate:  loan.InterestRate,
+		TermMonths:    loan.TermMonths,
+		Installment:   installment,
+		FirstDueDate:  firstDue,
+		EffectiveFrom: loan.StartDate,
+	}
+	return tx.Create(&terms).Error
+}
+
+func pendingInstallments(tx *gorm.DB, loanID uint) ([]models.LoanInstallment, error) {
+	var installments []models.LoanInstallment
+	err := tx.Where("loan_id = ? AND status = ?", loanID, models.InstallmentPending).Order("number asc").Find(&installments).Error
+	return installments, err
+}
+
+// rescheduleUnpaid rebuilds the installments nothing has been paid or charged on yet, after taking
+// prepaid off their principal and at the loan's current rate, and records the new terms. Partly
+// paid installments are kept as they are. loan.TermMonths and loan.TermsRevision are updated for
+// the caller to save.
+func rescheduleUnpaid(tx *gorm.DB, loan *models.Loan, prepaid float64, mode, reason string) error {
+	pending, err := pendingInstallments(tx, loan.ID)
+	if err != nil {
+		return err
+	}
+	var untouched []models.LoanInstallment
+	for _, installment := range pending {
+		if installment.PrincipalPaid == 0 && installment.InterestPaid == 0 && installment.Penalty == 0 {
+			untouched = append(untouched, installment)
+		}
+	}
+
+	var principal float64
+	for _, installment := range untouched {
+		principal += installment.Principal
+	}
+	principal = roundMoney(principal)
+	if prepaid > 0 && prepaid >= principal {
+		return ValidationError("prepayment_exceeds_principal", fmt.Sprintf("prepayment must be less than the %.2f of principal still to be scheduled, foreclose the loan to repay it in full", principal))
+	}
+	if len(untouched) == 0 {
+		return nil
+	}
+	principal = roundMoney(principal - prepaid)
+
+	months := len(untouched)
+	installment := roundMoney(emi(principal, loan.InterestRate, months))
+	if mode == RescheduleReduceTenure {
+		installment = untouched[0].Amount
+		months = tenureFor(principal, loan.InterestRate, installment)
+		if months == 0 {
+			return ConflictError("installment_below_interest", "the current installment no longer covers interest, reduce the installment instead")
+		}
+	}
+
+	first := untouched[0].Number
+	if err := tx.Where("loan_id = ? AND number >= ?", loan.ID, first).Delete(&models.LoanInstallment{}).Error; err != nil {
+		return err
+	}
+	schedule := buildSchedule(loan, principal, installment, months, first, untouched[0].DueDate)
+	if err := tx.Create(&schedule).Error; err != nil {
+		return err
+	}
+	loan.TermMonths += len(schedule) - len(untouched)
+	return recordTerms(tx, loan, &models.LoanTerms{
+		Principal:    principal,
+		TermMonths:   len(schedule),
+		Installment:  installment,
+		FirstDueDate: untouched[0].DueDate,
+		Reason:       reason,
+	})
+}
+
+func (s *LoanService) GetSchedule(ctx context.Context, id uint) ([]models.LoanInstallment, error) {
+	if _, err := s.GetByID(ctx, id, true); err != nil {
+		return nil, err
+	}
+	var installments []models.LoanInstallment
+	if err := s.db.WithContext(ctx).Where("loan_id = ?", id).Order("number asc").Find(&installments).Error; err != nil {
+		return nil, err
+	}
+	return installments, nil
+}
+
+// prepaymentRestriction reports why the product's prepayment rules forbid repaying early at the
+// given time, nil when they don't.
+func prepaymentRestriction(product *models.LoanProduct, loan *models.Loan, at time.Time) *Error {
+	if !product.PrepaymentAllowed {
+		return ConflictError("prepayment_not_allowed", product.Code+" loans cannot be prepaid")
+	}
+	lockInEnds := loan.StartDate.AddDate(0, product.PrepaymentLockInMonths, 0)
+	if at.Before(lockInEnds) {
+		return ConflictError("prepayment_locked_in", "prepayment is allowed from "+lockInEnds.Format("2006-01-02"))
+	}
+	return nil
+}
+
+type PayoffQuote struct {
+	LoanID               uint      `json:"loan_id"`
+	AsOf                 time.Time `json:"as_of"`
+	PrincipalOutstanding float64   `json:"principal_outstanding"`
+	OverdueInterest      float64   `json:"overdue_interest"`
+	AccruedInterest      float64   `json:"accrued_interest"`
+	AccruedSince         time.Time `json:"accrued_since"`
+	Penalties            float64   `json:"penalties"`
+	ForeclosureFee       float64   `json:"foreclosure_fee"`
+	Advance              float64   `json:"advance"`
+	Total                float64   `json:"total"`
+	Refund               float64   `json:"refund"`
+	ForeclosureAllowed   bool      `json:"foreclosure_allowed"`
+	Restriction          string    `json:"restriction,omitempty"`
+}
+
+// payoffQuote prices closing the loan at asOf: all principal still owed, interest of installments
+// already due, interest accrued on the rest since the last due date, late fees and the
+// foreclosure fee, less any advance held. An advance larger than that is refunded.
+func payoffQuote(tx *gorm.DB, loan *models.Loan, asOf time.Time) (*PayoffQuote, error) {
+	if loan.Status == "closed" {
+		return nil, ConflictError("loan_closed", "loan is already closed")
+	}
+	if asOf.Before(loan.StartDate) {
+		return nil, ValidationError("invalid_as_of", "as_of must not be before the loan start date")
+	}
+
+	var product models.LoanProduct
+	if err := tx.First(&product, loan.ProductID).Error; err != nil {
+		return nil, dbError(err, "loan_product")
+	}
+	var installments []models.LoanInstallment
+	if err := tx.Where("loan_id = ?", loan.ID).Order("number asc").Find(&installments).Error; err != nil {
+		return nil, err
+	}
+
+	//interest up to a restructuring was capitalized then, accrual restarts from it
+	quote := PayoffQuote{LoanID: loan.ID, AsOf: asOf, AccruedSince: loan.StartDate, Advance: loan.Advance}
+	if loan.RestructuredAt != nil && loan.RestructuredAt.Before(asOf) {
+		quote.AccruedSince = *loan.RestructuredAt
+	}
+	var notDue float64
+	for _, installment := range installments {
+		due := !installment.DueDate.After(asOf)
+		if due && installment.DueDate.After(quote.AccruedSince) {
+			quote.AccruedSince = installment.DueDate
+		}
+		if installment.Status != models.InstallmentPending {
+			continue
+		}
+		remaining := installment.Principal - installment.PrincipalPaid
+		quote.PrincipalOutstanding += remaining
+		quote.Penalties += latePenalty(&product, &installment, asOf) - installment.PenaltyPaid
+		if due {
+			quote.OverdueInterest += installment.Interest - installment.InterestPaid
+		} else {
+			notDue += remaining
+		}
+	}
+
+	days := math.Floor(asOf.Sub(quote.AccruedSince).Hours() / 24)
+	quote.PrincipalOutstanding = roundMoney(quote.PrincipalOutstanding)
+	quote.OverdueInterest = roundMoney(quote.OverdueInterest)
+	quote.Penalties = roundMoney(quote.Penalties)
+	quote.AccruedInterest = roundMoney(notDue * loan.InterestRate / 100 * days / 365)
+	quote.ForeclosureFee = roundMoney(quote.PrincipalOutstanding * product.PrepaymentPenaltyPercent / 100)
+	owed := quote.PrincipalOutstanding + quote.OverdueInterest + quote.AccruedInterest + quote.Penalties + quote.ForeclosureFee
+	quote.Total = roundMoney(math.Max(0, owed-loan.Advance))
+	quote.Refund = roundMoney(math.Max(0, loan.Advance-owed))
+
+	quote.ForeclosureAllowed = true
+	if restriction := prepaymentRestriction(&product, loan, asOf); restriction != nil {
+		quote.ForeclosureAllowed = false
+		quote.Restriction = restriction.Message
+	}
+	return &quote, nil
+}
+
+func (s *LoanService) Payoff(ctx context.Context, id uint, asOf time.Time) (*PayoffQuote, error) {
+	loan, err := s.GetByID(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return payoffQuote(s.db.WithContext(ctx), loan, asOf)
+}
+
+// ForeclosureResult has no transaction when the advance held exactly covered the payoff.
+type ForeclosureResult struct {
+	Loan        models.Loan         `json:"loan"`
+	Quote       PayoffQuote         `json:"quote"`
+	Repayment   models.Repayment    `json:"repayment"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+}
+
+// Foreclose settles today's payoff quote from the loan's account and closes the loan in one
+// transaction. Late fees are charged before quoting so they are settled with the rest.
+func (s *LoanService) Foreclose(ctx context.Context, id uint) (*ForeclosureResult, error) {
+	var result ForeclosureResult
+	now := time.Now()
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		if loan.Status != "closed" {
+			var product models.LoanProduct
+			if err := tx.First(&product, loan.ProductID).Error; err != nil {
+				return dbError(err, "loan_product")
+			}
+			installments, err := pendingInstallments(tx, loan.ID)
+			if err != nil {
+				return err
+			}
+			if err := assessPenalties(tx, &product, installments, now); err != nil {
+				return err
+			}
+		}
+		quote, err := payoffQuote(tx, &loan, now)
+		if err != nil {
+			return err
+		}
+		if !quote.ForeclosureAllowed {
+			return ConflictError("foreclosure_not_allowed", quote.Restriction)
+		}
+
+		account, err := lockAccount(tx, loan.AccountID)
+		if err != nil {
+			return err
+		}
+		var transaction *models.Transaction
+		switch {
+		case quote.Refund > 0:
+			if err := ensureCanCredit(tx, account); err != nil {
+				return err
+			}
+			transaction, err = postCredit(tx, account, "loan_refund", quote.Refund, fmt.Sprintf("advance returned on foreclosure of loan %d", loan.ID))
+		case quote.Total > 0:
+			if err := ensureCanDebit(account); err != nil {
+				return err
+			}
+			transaction, err = postDebit(tx, account, "loan_foreclosure", quote.Total, fmt.Sprintf("foreclosure of loan %d", loan.ID))
+		}
+		if err != nil {
+			return err
+		}
+
+		repayment := models.Repayment{
+			LoanID:         loan.ID,
+			Type:           models.RepaymentForeclosure,
+			Amount:         quote.Total,
+			AdvanceApplied: loan.Advance,
+			PaymentDate:    now,
+		}
+		if err := tx.Create(&repayment).Error; err != nil {
+			return err
+		}
+		var allocations []models.RepaymentAllocation
+		for _, part := range []struct {
+			component string
+			amount    float64
+		}{
+			{models.AllocationPenalty, quote.Penalties},
+			{models.AllocationFee, quote.ForeclosureFee},
+			{models.AllocationInterest, roundMoney(quote.OverdueInterest + quote.AccruedInterest)},
+			{models.AllocationPrincipal, quote.PrincipalOutstanding},
+			{models.AllocationRefund, quote.Refund},
+		} {
+			if part.amount > 0 {
+				allocations = append(allocations, models.RepaymentAllocation{Component: part.component, Amount: part.amount})
+			}
+		}
+		if err := saveAllocations(tx, &repayment, allocations); err != nil {
+			return err
+		}
+
+		//installments already due count as paid, the rest were settled early
+		pending := tx.Model(&models.LoanInstallment{}).Where("loan_id = ? AND status = ?", loan.ID, models.InstallmentPending)
+		if err := pending.Session(&gorm.Session{}).Where("due_date <= ?", now).Updates(map[string]interface{}{
+			"status":         models.InstallmentPaid,
+			"principal_paid": gorm.Expr("principal"),
+			"interest_paid":  gorm.Expr("interest"),
+			"penalty_paid":   gorm.Expr("penalty"),
+			"paid_at":        now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := pending.Session(&gorm.Session{}).Where("due_date > ?", now).Updates(map[string]interface{}{
+			"status":         models.InstallmentSettled,
+			"principal_paid": gorm.Expr("principal"),
+			"paid_at":        now,
+		}).Error; err != nil {
+			return err
+		}
+
+		loan.Status = "closed"
+		loan.Advance = 0
+		loan.Version++
+		if err := tx.Model(&loan).Updates(map[string]interface{}{"status": loan.Status, "advance": loan.Advance, "version": loan.Version}).Error; err != nil {
+			return err
+		}
+
+		result = ForeclosureResult{Loan: loan, Quote: *quote, Repayment: repayment, Transaction: transaction}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("loan foreclosure failed", "loan_id", id, "error", err)
+		return nil, err
+	}
+
+	metrics.LoansClosed.Inc()
+	logging.FromContext(ctx).Info("loan foreclosed", "loan_id", id, "amount", result.Quote.Total)
+	return &result, nil
+}
+
+type PrepaymentResult struct {
+	Loan      models.Loan              `json:"loan"`
+	Repayment models.Repayment         `json:"repayment"`
+	Penalty   float64                  `json:"penalty"`
+	Schedule  []models.LoanInstallment `json:"schedule"`
+}
+
+// Prepay pays amount off the principal from the loan's account and regenerates the unpaid
+// schedule, keeping the installment and shortening the tenure or the other way round.
+func (s *LoanService) Prepay(ctx context.Context, id uint, amount float64, mode string) (*PrepaymentResult, error) {
+	if amount <= 0 {
+		return nil, ValidationError("invalid_amount", "amount must be greater than zero")
+	}
+
+	var result PrepaymentResult
+	now := time.Now()
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		if loan.Status == "closed" {
+			return ConflictError("loan_closed", "loan is already closed")
+		}
+		var product models.LoanProduct
+		if err := tx.First(&product, loan.ProductID).Error; err != nil {
+			return dbError(err, "loan_product")
+		}
+		if restriction := prepaymentRestriction(&product, &loan, now); restriction != nil {
+			return restriction
+		}
+
+		var overdue int64
+		if err := tx.Model(&models.LoanInstallment{}).
+			Where("loan_id = ? AND status = ? AND due_date <= ?", loan.ID, models.InstallmentPending, now).
+			Count(&overdue).Error; err != nil {
+			return err
+		}
+		if overdue > 0 {
+			return ConflictError("installments_overdue", "overdue installments have to be repaid before prepaying")
+		}
+
+		if err := rescheduleUnpaid(tx, &loan, amount, mode, "prepayment, "+mode); err != nil {
+			return err
+		}
+
+		account, err := lockAccount(tx, loan.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureCanDebit(account); err != nil {
+			return err
+		}
+		if _, err := postDebit(tx, account, "loan_prepayment", amount, fmt.Sprintf("prepayment of loan %d", loan.ID)); err != nil {
+			return err
+		}
+		penalty := roundMoney(amount * product.PrepaymentPenaltyPercent / 100)
+		if penalty > 0 {
+			if _, err := postDebit(tx, account, "prepayment_penalty", penalty, fmt.Sprintf("prepayment charge on loan %d", loan.ID)); err != nil {
+				return err
+			}
+		}
+
+		//the penalty is part of what was paid, so the allocations add up to the repayment
+		repayment := models.Repayment{
+			LoanID:      loan.ID,
+			Type:        models.RepaymentPrepayment,
+			Amount:      roundMoney(amount + penalty),
+			PaymentDate: now,
+		}
+		if err := tx.Create(&repayment).Error; err != nil {
+			return err
+		}
+		allocations := []models.RepaymentAllocation{{Component: models.AllocationPrincipal, Amount: amount}}
+		if penalty > 0 {
+			allocations = append([]models.RepaymentAllocation{{Component: models.AllocationPenalty, Amount: penalty}}, allocations...)
+		}
+		if err := saveAllocations(tx, &repayment, allocations); err != nil {
+			return err
+		}
+
+		loan.Version++
+		if err := tx.Model(&loan).Updates(map[string]interface{}{"term_months": loan.TermMonths, "terms_revision": loan.TermsRevision, "version": loan.Version}).Error; err != nil {
+			return err
+		}
+
+		schedule, err := pendingInstallments(tx, loan.ID)
+		if err != nil {
+			return err
+		}
+		result = PrepaymentResult{Loan: loan, Repayment: repayment, Penalty: penalty, Schedule: schedule}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("loan prepayment failed", "loan_id", id, "amount", amount, "error", err)
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("loan prepaid", "loan_id", id, "amount", amount, "mode", mode, "term_months", result.Loan.TermMonths)
+	return &result, nil
+}
+
+// principalOutstanding is the principal of a loan's unpaid installments.
+func principalOutstanding(db *gorm.DB, loanID uint) (float64, error) {
+	var outstanding float64
+	err := db.Model(&models.LoanInstallment{}).
+		Where("loan_id = ? AND status = ?", loanID, models.InstallmentPending).
+		Select("COALESCE(SUM(principal - principal_paid), 0)").Scan(&outstanding).Error
+	return roundMoney(outstanding), err
+}
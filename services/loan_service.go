@@ -1,135 +1,260 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"math"
 	"time"
 
+	"banking_system/logging"
+	"banking_system/metrics"
 	"banking_system/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LoanService struct {
 	db *gorm.DB
+	// officerRoles may restructure loans
+	officerRoles []string
 }
 
-func NewLoanService(db *gorm.DB) *LoanService {
-	return &LoanService{db: db}
+func NewLoanService(db *gorm.DB, officerRoles []string) *LoanService {
+	return &LoanService{db: db, officerRoles: officerRoles}
 }
 
-func (s *LoanService) Create(loan *models.Loan) error {
-	if loan.InterestRate == 0 {
-		loan.InterestRate = 12.0
+// create books a loan inside the caller's transaction, loans only come from approved applications.
+// Amount and term are checked against the product, which also sets the rate and processing fee.
+func (s *LoanService) create(tx *gorm.DB, loan *models.Loan) error {
+	if err := ensureExists(tx, &models.Account{}, loan.AccountID, "account_id", "account"); err != nil {
+		return err
 	}
+	if err := ensureExists(tx, &models.Customer{}, loan.CustomerID, "customer_id", "customer"); err != nil {
+		return err
+	}
+	product, err := activeProduct(tx, loan.ProductID)
+	if err != nil {
+		return err
+	}
+	if err := checkProductTerms(product, loan.Amount, loan.TermMonths, "loan_amount"); err != nil {
+		return err
+	}
+	rate, err := productRate(tx, product)
+	if err != nil {
+		return err
+	}
+	loan.InterestRate = rate
+	loan.RateType = product.RateType
+	loan.BenchmarkID = product.BenchmarkID
+	loan.Spread = product.Spread
+	loan.ProcessingFee = processingFee(product, loan.Amount)
 	if loan.StartDate.IsZero() {
 		loan.StartDate = time.Now()
 	}
 	if loan.Status == "" {
 		loan.Status = "ongoing"
 	}
-	return s.db.Create(loan).Error
+	if err := tx.Create(loan).Error; err != nil {
+		return dbError(err, "loan")
+	}
+	return generateSchedule(tx, loan)
 }
 
-func (s *LoanService) GetByID(id uint) (*models.Loan, error) {
+func (s *LoanService) GetByID(ctx context.Context, id uint, includeDeleted bool) (*models.Loan, error) {
 	var loan models.Loan
-	if err := s.db.First(&loan, id).Error; err != nil {
-		return nil, err
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).First(&loan, id).Error; err != nil {
+		return nil, dbError(err, "loan")
 	}
 	return &loan, nil
 }
 
-func (s *LoanService) GetAll() ([]models.Loan, error) {
+func (s *LoanService) GetAll(ctx context.Context, includeDeleted bool) ([]models.Loan, error) {
 	var loans []models.Loan
-	if err := s.db.Find(&loans).Error; err != nil {
+	if err := scoped(s.db.WithContext(ctx), includeDeleted).Find(&loans).Error; err != nil {
 		return nil, err
 	}
 	return loans, nil
 }
 
-func (s *LoanService) Update(loan *models.Loan) error {
-	return s.db.Save(loan).Error
+// Delete archives a closed loan, ongoing loans have to be repaid first.
+func (s *LoanService) Delete(ctx context.Context, id, version uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var loan models.Loan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, id).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		if err := checkVersion(loan.Version, version, "loan"); err != nil {
+			return err
+		}
+		if loan.Status != "closed" {
+			return ConflictError("loan_not_closed", "only closed loans can be archived")
+		}
+		return dbError(tx.Delete(&loan).Error, "loan")
+	})
 }
 
-func (s *LoanService) Delete(id uint) error {
-	return s.db.Delete(&models.Loan{}, id).Error
+func (s *LoanService) Restore(ctx context.Context, id uint) (*models.Loan, error) {
+	if err := restoreDeleted(s.db.WithContext(ctx), &models.Loan{}, id, "loan"); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id, false)
 }
 
 type LoanDetails struct {
-	Loan               models.Loan `json:"loan"`
-	TotalRepaid        float64     `json:"total_repaid"`
-	LoanPending        float64     `json:"loan_pending"`
-	InterestDueThisYear float64    `json:"interest_due_this_year"`
+	Loan                 models.Loan `json:"loan"`
+	TotalRepaid          float64     `json:"total_repaid"`
+	PrincipalOutstanding float64     `json:"principal_outstanding"`
+	InterestOverdue      float64     `json:"interest_overdue"`
+	InterestScheduled    float64     `json:"interest_scheduled"`
+	PenaltiesOutstanding float64     `json:"penalties_outstanding"`
+	Advance              float64     `json:"advance"`
+	LoanPending          float64     `json:"loan_pending"`
+	InterestDueThisYear  float64     `json:"interest_due_this_year"`
 }
 
-func (s *LoanService) GetDetails(id uint) (*LoanDetails, error) {
-	loan, err := s.GetByID(id)
+// GetDetails reads what is still owed off the schedule. Interest is split into what is already
+// due and what later installments will charge, loan_pending is everything owed less the advance.
+func (s *LoanService) GetDetails(ctx context.Context, id uint) (*LoanDetails, error) {
+	loan, err := s.GetByID(ctx, id, false)
 	if err != nil {
 		return nil, err
 	}
 
 	var totalRepaid float64
-	if err := s.db.Model(&models.Repayment{}).
+	if err := s.db.WithContext(ctx).Model(&models.Repayment{}).
 		Where("loan_id = ?", id).
 		Select("COALESCE(SUM(amount), 0)").Scan(&totalRepaid).Error; err != nil {
 		return nil, err
 	}
-
-	pending := loan.Amount - totalRepaid
-	if pending < 0 {
-		pending = 0
+	installments, err := pendingInstallments(s.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
 	}
-	interestThisYear := pending * loan.InterestRate / 100.0
 
-	return &LoanDetails{
-		Loan:               *loan,
-		TotalRepaid:        totalRepaid,
-		LoanPending:        pending,
-		InterestDueThisYear: interestThisYear,
-	}, nil
+	details := LoanDetails{Loan: *loan, TotalRepaid: totalRepaid, Advance: loan.Advance}
+	now := time.Now()
+	for _, installment := range installments {
+		interest := installment.Interest - installment.InterestPaid
+		details.PrincipalOutstanding += installment.Principal - installment.PrincipalPaid
+		details.PenaltiesOutstanding += installment.Penalty - installment.PenaltyPaid
+		if installment.DueDate.After(now) {
+			details.InterestScheduled += interest
+		} else {
+			details.InterestOverdue += interest
+		}
+		if installment.DueDate.Year() == now.Year() {
+			details.InterestDueThisYear += interest
+		}
+	}
+	details.PrincipalOutstanding = roundMoney(details.PrincipalOutstanding)
+	details.InterestOverdue = roundMoney(details.InterestOverdue)
+	details.InterestScheduled = roundMoney(details.InterestScheduled)
+	details.PenaltiesOutstanding = roundMoney(details.PenaltiesOutstanding)
+	details.InterestDueThisYear = roundMoney(details.InterestDueThisYear)
+	details.LoanPending = roundMoney(math.Max(0, details.PrincipalOutstanding+details.InterestOverdue+details.InterestScheduled+details.PenaltiesOutstanding-loan.Advance))
+	return &details, nil
 }
 
-func (s *LoanService) Repay(loanID uint, amount float64, paymentDate time.Time) (*models.Repayment, error) {
+// Repay runs the payment, together with any advance held on the loan, down the schedule: late
+// fees, then interest and principal oldest installment first. Money left once the schedule is
+// covered is refunded to the loan's account or held as advance for the next repayment, and is
+// always refunded when the loan closes.
+func (s *LoanService) Repay(ctx context.Context, loanID uint, amount float64, paymentDate time.Time, overpayment string) (*models.Repayment, error) {
 	if amount <= 0 {
-		return nil, errors.New("amount must be greater than zero")
+		return nil, ValidationError("invalid_amount", "amount must be greater than zero")
+	}
+	if overpayment == "" {
+		overpayment = OverpaymentAdvance
 	}
 
 	var repaymentRecord *models.Repayment
+	closed := false
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var loan models.Loan
-		if err := tx.First(&loan, loanID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&loan, loanID).Error; err != nil {
+			return dbError(err, "loan")
+		}
+		if loan.Status == "closed" {
+			return ConflictError("loan_closed", "loan is already closed")
+		}
+		var product models.LoanProduct
+		if err := tx.First(&product, loan.ProductID).Error; err != nil {
+			return dbError(err, "loan_product")
+		}
+
+		installments, err := pendingInstallments(tx, loanID)
+		if err != nil {
+			return err
+		}
+		if err := assessPenalties(tx, &product, installments, paymentDate); err != nil {
+			return err
+		}
+		allocations, left, err := allocate(tx, installments, amount+loan.Advance, paymentDate)
+		if err != nil {
 			return err
 		}
 
+		closed = true
+		for _, installment := range installments {
+			if installment.Status == models.InstallmentPending {
+				closed = false
+				break
+			}
+		}
+
 		repayment := models.Repayment{
-			LoanID:      loanID,
-			Amount:      amount,
-			PaymentDate: paymentDate,
+			LoanID:         loanID,
+			Type:           models.RepaymentInstallment,
+			Amount:         amount,
+			AdvanceApplied: loan.Advance,
+			PaymentDate:    paymentDate,
 		}
 		if err := tx.Create(&repayment).Error; err != nil {
 			return err
 		}
-		repaymentRecord = &repayment
 
-		var totalRepaid float64
-		if err := tx.Model(&models.Repayment{}).
-			Where("loan_id = ?", loanID).
-			Select("COALESCE(SUM(amount), 0)").Scan(&totalRepaid).Error; err != nil {
+		loan.Advance = 0
+		if left > 0 {
+			if overpayment == OverpaymentRefund || closed {
+				account, err := lockAccount(tx, loan.AccountID)
+				if err != nil {
+					return err
+				}
+				if err := ensureCanCredit(tx, account); err != nil {
+					return err
+				}
+				if _, err := postCredit(tx, account, "loan_refund", left, fmt.Sprintf("overpayment on loan %d", loan.ID)); err != nil {
+					return err
+				}
+				allocations = append(allocations, models.RepaymentAllocation{Component: models.AllocationRefund, Amount: left})
+			} else {
+				loan.Advance = left
+				allocations = append(allocations, models.RepaymentAllocation{Component: models.AllocationAdvance, Amount: left})
+			}
+		}
+		if err := saveAllocations(tx, &repayment, allocations); err != nil {
 			return err
 		}
+		repaymentRecord = &repayment
 
-		if totalRepaid >= loan.Amount && loan.Status != "closed" {
-			loan.Status = "closed"
-			if err := tx.Save(&loan).Error; err != nil {
-				return err
-			}
+		changes := map[string]interface{}{"advance": loan.Advance, "version": loan.Version + 1}
+		if closed {
+			changes["status"] = "closed"
 		}
-		return nil
+		return tx.Model(&loan).Updates(changes).Error
 	})
 
 	if err != nil {
+		logging.FromContext(ctx).Warn("loan repayment failed", "loan_id", loanID, "amount", amount, "error", err)
 		return nil, err
 	}
+	logging.FromContext(ctx).Info("loan repayment recorded", "loan_id", loanID, "repayment_id", repaymentRecord.ID, "amount", amount)
+	if closed {
+		metrics.LoansClosed.Inc()
+		logging.FromContext(ctx).Info("loan closed", "loan_id", loanID)
+	}
 	return repaymentRecord, nil
 }
 
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+
+	"banking_system/models"
+
+	"gorm.io/gorm"
+)
+
+var holderRoles = map[string]bool{
+	models.HolderRolePrimary:             true,
+	models.HolderRoleJoint:               true,
+	models.HolderRoleNominee:             true,
+	models.HolderRoleAuthorizedSignatory: true,
+	models.HolderRoleGuardian:            true,
+}
+
+var mandates = map[string]bool{
+	models.MandateEitherOrSurvivor: true,
+	models.MandateFormerOrSurvivor: true,
+	models.MandateJointly:          true,
+}
+
+// isHolder is true for the roles that own the account, the others only act on it.
+func isHolder(role string) bool {
+	return role == models.HolderRolePrimary || role == models.HolderRoleJoint
+}
+
+// canOperate is true for the roles allowed to give instructions, nominees only inherit.
+func canOperate(role string) bool {
+	return role != models.HolderRoleNominee
+}
+
+func accountLinks(tx *gorm.DB, accountID uint) ([]models.AccountCustomer, error) {
+	var links []models.AccountCustomer
+	if err := tx.Where("account_id = ?", accountID).Order("created_at asc, agreement_id asc").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// syncJoint keeps the joint flag in step with the number of holders: two or more make the account
+// joint. account_type (savings or current) is the product and never changes with the holders.
+func syncJoint(tx *gorm.DB, account *models.Account) error {
+	var holders int64
+	if err := tx.Model(&models.AccountCustomer{}).
+		Where("account_id = ? AND role IN ?", account.ID, []string{models.HolderRolePrimary, models.HolderRoleJoint}).
+		Count(&holders).Error; err != nil {
+		return err
+	}
+
+	joint := holders >= 2
+	if joint == account.Joint {
+		return nil
+	}
+
+	account.Joint = joint
+	account.Version++
+	return tx.Model(account).Updates(map[string]interface{}{"joint": joint, "version": account.Version}).Error
+}
+
+// checkMandate verifies that the customers instructing a debit may do so under the account's
+// operating mandate. A guardian signs in place of the (minor) primary holder.
+func checkMandate(tx *gorm.DB, account *models.Account, signers []uint) error {
+	if len(signers) == 0 {
+		return ForbiddenError("instruction_required", "customer_ids of the customers giving the instruction are required")
+	}
+
+	links, err := accountLinks(tx, account.ID)
+	if err != nil {
+		return err
+	}
+	roles := make(map[uint]string, len(links))
+	for _, link := range links {
+		roles[link.CustomerID] = link.Role
+	}
+
+	signed := make(map[string]bool)
+	signedBy := make(map[uint]bool)
+	for _, id := range signers {
+		role, ok := roles[id]
+		if !ok || !canOperate(role) {
+			return ForbiddenError("customer_not_authorized", fmt.Sprintf("customer %d cannot operate this account", id))
+		}
+		signed[role] = true
+		signedBy[id] = true
+	}
+	primarySigned := signed[models.HolderRolePrimary] || signed[models.HolderRoleGuardian]
+
+	switch account.OperatingMandate {
+	case models.MandateFormerOrSurvivor:
+		if !primarySigned {
+			return ForbiddenError("mandate_not_satisfied", "the primary holder has to give the instruction on a former or survivor account")
+		}
+	case models.MandateJointly:
+		for _, link := range links {
+			if !isHolder(link.Role) || signedBy[link.CustomerID] {
+				continue
+			}
+			if link.Role == models.HolderRolePrimary && primarySigned {
+				continue
+			}
+			return ForbiddenError("mandate_not_satisfied", fmt.Sprintf("customer %d also has to sign, the account is operated jointly", link.CustomerID))
+		}
+	}
+	return nil
+}
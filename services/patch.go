@@ -0,0 +1,57 @@
+package services
+
+import (
+	"gorm.io/gorm"
+)
+
+// applyChanges writes only the given columns. Callers build the map from an allow-list of
+// client-editable fields, so server-owned columns can never end up in it. The write is
+// conditional on version unless it is zero (If-Match: *), and bumps it on success.
+func applyChanges(db *gorm.DB, model interface{}, id, version uint, changes map[string]interface{}, entity string) error {
+	if len(changes) == 0 {
+		return ValidationError("empty_update", "no updatable fields supplied")
+	}
+	changes["version"] = gorm.Expr("version + 1")
+
+	query := db.Model(model).Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	result := query.Updates(changes)
+	if result.Error != nil {
+		return dbError(result.Error, entity)
+	}
+	if result.RowsAffected == 0 {
+		return staleOrMissing(db, model, id, entity)
+	}
+	return nil
+}
+
+// deleteVersioned deletes the row only if it is still at the given version.
+func deleteVersioned(db *gorm.DB, model interface{}, id, version uint, entity string) error {
+	query := db.Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	result := query.Delete(model)
+	if result.Error != nil {
+		return dbError(result.Error, entity)
+	}
+	if result.RowsAffected == 0 {
+		return staleOrMissing(db, model, id, entity)
+	}
+	return nil
+}
+
+func staleOrMissing(db *gorm.DB, model interface{}, id uint, entity string) error {
+	var count int64
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return NotFoundError(entity+"_not_found", entity+" not found")
+	}
+	return PreconditionFailedError(entity+"_version_mismatch", entity+" was modified by another request, fetch it again and retry")
+}
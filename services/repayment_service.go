@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"time"
 
 	"banking_system/models"
@@ -9,41 +10,39 @@ import (
 )
 
 type RepaymentService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	loans *LoanService
 }
 
-func NewRepaymentService(db *gorm.DB) *RepaymentService {
-	return &RepaymentService{db: db}
+func NewRepaymentService(db *gorm.DB, loans *LoanService) *RepaymentService {
+	return &RepaymentService{db: db, loans: loans}
 }
 
-func (s *RepaymentService) Create(repayment *models.Repayment) error {
-	if repayment.PaymentDate.IsZero() {
-		repayment.PaymentDate = time.Now()
+// Create takes the repayment through the loan's allocation waterfall, a repayment is never
+// recorded without settling the schedule. Recorded repayments can't be edited or removed.
+func (s *RepaymentService) Create(ctx context.Context, loanID uint, amount float64, paymentDate time.Time, overpayment string) (*models.Repayment, error) {
+	if err := ensureExists(s.db.WithContext(ctx), &models.Loan{}, loanID, "loan_id", "loan"); err != nil {
+		return nil, err
+	}
+	if paymentDate.IsZero() {
+		paymentDate = time.Now()
 	}
-	return s.db.Create(repayment).Error
+	return s.loans.Repay(ctx, loanID, amount, paymentDate, overpayment)
 }
 
-func (s *RepaymentService) GetByID(id uint) (*models.Repayment, error) {
+func (s *RepaymentService) GetByID(ctx context.Context, id uint) (*models.Repayment, error) {
 	var repayment models.Repayment
-	if err := s.db.First(&repayment, id).Error; err != nil {
-		return nil, err
+	if err := s.db.WithContext(ctx).Preload("Allocations").First(&repayment, id).Error; err != nil {
+		return nil, dbError(err, "repayment")
 	}
 	return &repayment, nil
 }
 
-func (s *RepaymentService) GetAll() ([]models.Repayment, error) {
+func (s *RepaymentService) GetAll(ctx context.Context) ([]models.Repayment, error) {
 	var repayments []models.Repayment
-	if err := s.db.Find(&repayments).Error; err != nil {
+	if err := s.db.WithContext(ctx).Find(&repayments).Error; err != nil {
 		return nil, err
 	}
 	return repayments, nil
 }
 
-func (s *RepaymentService) Update(repayment *models.Repayment) error {
-	return s.db.Save(repayment).Error
-}
-
-func (s *RepaymentService) Delete(id uint) error {
-	return s.db.Delete(&models.Repayment{}, id).Error
-}
-
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"banking_system/logging"
+	"banking_system/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// occurrenceDate is the date of occurrence n (from 0) of an instruction starting at start.
+func occurrenceDate(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case models.FrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case models.FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case models.FrequencyQuarterly:
+		return start.AddDate(0, 3*n, 0)
+	case models.FrequencyYearly:
+		return start.AddDate(n, 0, 0)
+	}
+	return start.AddDate(0, n, 0)
+}
+
+// advanceOccurrence moves the instruction to its next occurrence, completing it past the end date.
+func advanceOccurrence(instruction *models.StandingInstruction) {
+	instruction.Occurrence++
+	instruction.Attempts = 0
+	instruction.NextDueDate = occurrenceDate(instruction.StartDate, instruction.Frequency, instruction.Occurrence)
+	instruction.NextRunAt = instruction.NextDueDate
+	if instruction.EndDate != nil && instruction.NextDueDate.After(*instruction.EndDate) {
+		instruction.Status = models.InstructionCompleted
+	}
+}
+
+// catchUp skips the occurrences that came due while the instruction wasn't running except the
+// latest one, which runs straight away.
+func catchUp(instruction *models.StandingInstruction, now time.Time) {
+	instruction.Attempts = 0
+	for !occurrenceDate(instruction.StartDate, instruction.Frequency, instruction.Occurrence+1).After(now) {
+		advanceOccurrence(instruction)
+	}
+	instruction.NextRunAt = instruction.NextDueDate
+	if instruction.NextRunAt.Before(now) {
+		instruction.NextRunAt = now
+	}
+}
+
+type StandingInstructionService struct {
+	db        *gorm.DB
+	accounts  *AccountService
+	approvals *ApprovalService
+}
+
+// NewStandingInstructionService registers the approval of standing instructions at or above the
+// transfer threshold. Scheduled runs share the service, so they re-check the same threshold.
+func NewStandingInstructionService(db *gorm.DB, accounts *AccountService, approvals *ApprovalService) *StandingInstructionService {
+	s := &StandingInstructionService{db: db, accounts: accounts, approvals: approvals}
+	if approvals != nil {
+		approvals.Register(OpStandingInstruction, s.executeApproval)
+		approvals.OnReject(OpStandingInstruction, s.rejectApproval)
+	}
+	return s
+}
+
+type instructionPayload struct {
+	InstructionID uint `json:"instruction_id"`
+}
+
+// Create checks the instruction against the source account's mandate now, so a standing
+// instruction nobody could have given is refused up front instead of failing on every run. An
+// amount at or above the transfer threshold leaves the instruction pending_approval until a
+// checker approves it.
+func (s *StandingInstructionService) Create(ctx context.Context, instruction *models.StandingInstruction) error {
+	if instruction.SourceAccountID == instruction.DestinationAccountID {
+		return ValidationError("same_account", "source and destination accounts must differ")
+	}
+	if instruction.EndDate != nil && instruction.EndDate.Before(instruction.StartDate) {
+		return FieldValidationError(FieldError{Field: "end_date", Message: "end_date must not be before start_date"})
+	}
+
+	db := s.db.WithContext(ctx)
+	if err := ensureExists(db, &models.Account{}, instruction.SourceAccountID, "source_account_id", "account"); err != nil {
+		return err
+	}
+	if err := ensureExists(db, &models.Account{}, instruction.DestinationAccountID, "destination_account_id", "account"); err != nil {
+		return err
+	}
+	var source models.Account
+	if err := db.First(&source, instruction.SourceAccountID).Error; err != nil {
+		return dbError(err, "account")
+	}
+	if source.Status == models.AccountStatusClosed {
+		return accountStatusError(&source)
+	}
+	if err := checkMandate(db, &source, instruction.CustomerIDs); err != nil {
+		return err
+	}
+
+	needsApproval := s.approvals.requiresApproval(ctx, OpStandingInstruction, instruction.Amount)
+	instruction.Status = models.InstructionActive
+	if needsApproval {
+		instruction.Status = models.InstructionPendingApproval
+	}
+	instruction.Occurrence = 0
+	instruction.NextDueDate = instruction.StartDate
+	instruction.NextRunAt = instruction.StartDate
+	//a pending instruction is only kept along with the request that can start it
+	var request *models.ApprovalRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(instruction).Error; err != nil {
+			return dbError(err, "standing_instruction")
+		}
+		if !needsApproval {
+			return nil
+		}
+		var err error
+		request, err = s.approvals.park(ctx, tx, OpStandingInstruction, "standing_instruction", instruction.ID, instruction.Amount, instructionPayload{InstructionID: instruction.ID})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("standing instruction created", "instruction_id", instruction.ID, "source_account_id", instruction.SourceAccountID, "destination_account_id", instruction.DestinationAccountID, "amount", instruction.Amount, "frequency", instruction.Frequency, "status", instruction.Status)
+
+	if request != nil {
+		return pending(ctx, request)
+	}
+	return nil
+}
+
+// executeApproval starts the approved instruction, skipping occurrences that came due while it
+// waited.
+func (s *StandingInstructionService) executeApproval(ctx context.Context, tx *gorm.DB, payload json.RawMessage) (interface{}, error) {
+	var p instructionPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	approvalID, _ := ctx.Value(approvedKey{}).(uint)
+
+	var instruction models.StandingInstruction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&instruction, p.InstructionID).Error; err != nil {
+		return nil, dbError(err, "standing_instruction")
+	}
+	if instruction.Status != models.InstructionPendingApproval {
+		return nil, ConflictError("instruction_"+instruction.Status, "standing instruction is "+instruction.Status)
+	}
+	instruction.Status = models.InstructionActive
+	instruction.ApprovalID = &approvalID
+	catchUp(&instruction, time.Now())
+	instruction.Version++
+	if err := saveInstruction(tx, &instruction); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("standing instruction approved", "instruction_id", instruction.ID, "approval_id", approvalID)
+	return &instruction, nil
+}
+
+// rejectApproval cancels the instruction the checker turned down.
+func (s *StandingInstructionService) rejectApproval(ctx context.Context, tx *gorm.DB, request *models.ApprovalRequest) error {
+	var p instructionPayload
+	if err := json.Unmarshal(request.Payload, &p); err != nil {
+		return err
+	}
+	result := tx.Model(&models.StandingInstruction{}).
+		Where("id = ? AND status = ?", p.InstructionID, models.InstructionPendingApproval).
+		Updates(map[string]interface{}{"status": models.InstructionCancelled, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		logging.FromContext(ctx).Info("standing instruction rejected", "instruction_id", p.InstructionID, "checker", request.CheckerID)
+	}
+	return nil
+}
+
+// needsApproval is true for instructions at or above the transfer threshold that no checker has
+// approved yet.
+func (s *StandingInstructionService) needsApproval(ctx context.Context, instruction *models.StandingInstruction) bool {
+	return instruction.ApprovalID == nil && s.approvals.requiresApproval(ctx, OpStandingInstruction, instruction.Amount)
+}
+
+func (s *StandingInstructionService) GetByID(ctx context.Context, id uint) (*models.StandingInstruction, error) {
+	var instruction models.StandingInstruction
+	if err := s.db.WithContext(ctx).First(&instruction, id).Error; err != nil {
+		return nil, dbError(err, "standing_instruction")
+	}
+	return &instruction, nil
+}
+
+// GetAll lists the instructions, optionally only those paying out of or into an account.
+func (s *StandingInstructionService) GetAll(ctx context.Context, accountID uint) ([]models.StandingInstruction, error) {
+	query := s.db.WithContext(ctx).Order("id asc")
+	if accountID != 0 {
+		query = query.Where("source_account_id = ? OR destination_account_id = ?", accountID, accountID)
+	}
+	var instructions []models.StandingInstruction
+	if err := query.Find(&instructions).Error; err != nil {
+		return nil, err
+	}
+	return instructions, nil
+}
+
+func (s *StandingInstructionService) GetExecutions(ctx context.Context, id uint) ([]models.StandingInstructionExecution, error) {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	var executions []models.StandingInstructionExecution
+	if err := s.db.WithContext(ctx).Where("instruction_id = ?", id).Order("id asc").Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+func (s *StandingInstructionService) Pause(ctx context.Context, id uint, reason string) (*models.StandingInstruction, error) {
+	return s.changeStatus(ctx, id, func(_ *gorm.DB, instruction *models.StandingInstruction) error {
+		if instruction.Status != models.InstructionActive {
+			return ConflictError("instruction_"+instruction.Status, "standing instruction is "+instruction.Status)
+		}
+		instruction.Status = models.InstructionPaused
+		instruction.PausedReason = reason
+		return nil
+	})
+}
+
+// Resume restarts a paused instruction. Occurrences that came due while it was paused are
+// skipped except the latest one, which runs straight away. An instruction that needs approval
+// and never got it waits for a checker instead.
+func (s *StandingInstructionService) Resume(ctx context.Context, id uint) (*models.StandingInstruction, error) {
+	var request *models.ApprovalRequest
+	instruction, err := s.changeStatus(ctx, id, func(tx *gorm.DB, instruction *models.StandingInstruction) error {
+		if instruction.Status != models.InstructionPaused {
+			return ConflictError("instruction_"+instruction.Status, "standing instruction is "+instruction.Status)
+		}
+		instruction.PausedReason = ""
+		instruction.ConsecutiveFailures = 0
+		if s.needsApproval(ctx, instruction) {
+			instruction.Status = models.InstructionPendingApproval
+			var err error
+			request, err = s.approvals.park(ctx, tx, OpStandingInstruction, "standing_instruction", id, instruction.Amount, instructionPayload{InstructionID: id})
+			return err
+		}
+		instruction.Status = models.InstructionActive
+		catchUp(instruction, time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if request != nil {
+		return nil, pending(ctx, request)
+	}
+	return instruction, nil
+}
+
+func (s *StandingInstructionService) Cancel(ctx context.Context, id uint) (*models.StandingInstruction, error) {
+	return s.changeStatus(ctx, id, func(_ *gorm.DB, instruction *models.StandingInstruction) error {
+		switch instruction.Status {
+		case models.InstructionCancelled, models.InstructionCompleted:
+			return ConflictError("instruction_"+instruction.Status, "standing instruction is "+instruction.Status)
+		}
+		instruction.Status = models.InstructionCancelled
+		return nil
+	})
+}
+
+func (s *StandingInstructionService) changeStatus(ctx context.Context, id uint, change func(*gorm.DB, *models.StandingInstruction) error) (*models.StandingInstruction, error) {
+	var instruction models.StandingInstruction
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&instruction, id).Error; err != nil {
+			return dbError(err, "standing_instruction")
+		}
+		if err := change(tx, &instruction); err != nil {
+			return err
+		}
+		instruction.Version++
+		return saveInstruction(tx, &instruction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("standing instruction status changed", "instruction_id", id, "status", instruction.Status)
+	return &instruction, nil
+}
+
+func saveInstruction(tx *gorm.DB, instruction *models.StandingInstruction) error {
+	return tx.Model(instruction).Updates(map[string]interface{}{
+		"status":               instruction.Status,
+		"occurrence":           instruction.Occurrence,
+		"next_due_date":        instruction.NextDueDate,
+		"next_run_at":          instruction.NextRunAt,
+		"attempts":             instruction.Attempts,
+		"consecutive_failures": instruction.ConsecutiveFailures,
+		"paused_reason":        instruction.PausedReason,
+		"last_executed_at":     instruction.LastExecutedAt,
+		"version":              instruction.Version,
+	}).Error
+}
+
+// RunDue executes every active instruction whose next run is due by now and returns how many
+// executions succeeded and failed.
+func (s *StandingInstructionService) RunDue(ctx context.Context, now time.Time) (succeeded, failed int, err error) {
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(&models.StandingInstruction{}).
+		Where("status = ? AND next_run_at <= ?", models.InstructionActive, now).
+		Order("next_run_at asc").Pluck("id", &ids).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		execution, err := s.execute(ctx, id, now)
+		if err != nil {
+			return succeeded, failed, err
+		}
+		switch {
+		case execution == nil:
+		case execution.Status == models.ExecutionSucceeded:
+			succeeded++
+		default:
+			failed++
+		}
+	}
+	return succeeded, failed, nil
+}
+
+// execute runs one attempt of the instruction's current occurrence through the account transfer
+// path. Failures the customer can fix (insufficient balance, frozen account, ...) are recorded on
+// the execution, then retried, skipped or pause the instruction as its retry policy says.
+func (s *StandingInstructionService) execute(ctx context.Context, id uint, now time.Time) (*models.StandingInstructionExecution, error) {
+	var execution *models.StandingInstructionExecution
+	var instruction models.StandingInstruction
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&instruction, id).Error; err != nil {
+			return err
+		}
+		if instruction.Status != models.InstructionActive || instruction.NextRunAt.After(now) {
+			return nil
+		}
+		if instruction.EndDate != nil && instruction.NextDueDate.After(*instruction.EndDate) {
+			instruction.Status = models.InstructionCompleted
+			instruction.Version++
+			return saveInstruction(tx, &instruction)
+		}
+
+		//the threshold may have dropped since the instruction was set up
+		if s.needsApproval(ctx, &instruction) {
+			instruction.Status = models.InstructionPaused
+			instruction.PausedReason = "the amount now needs approval, resume the instruction to request it"
+			instruction.Version++
+			logging.FromContext(ctx).Warn("standing instruction paused for approval", "instruction_id", instruction.ID, "amount", instruction.Amount)
+			return saveInstruction(tx, &instruction)
+		}
+
+		execution = &models.StandingInstructionExecution{
+			InstructionID: instruction.ID,
+			DueDate:       instruction.NextDueDate,
+			Attempt:       instruction.Attempts + 1,
+			Amount:        instruction.Amount,
+			ExecutedAt:    now,
+		}
+		description := instruction.Description
+		if description == "" {
+			description = fmt.Sprintf("standing instruction %d", instruction.ID)
+		}
+
+		//runs go through the approval the instruction was given, the transfer runs in a savepoint
+		runCtx := ctx
+		if instruction.ApprovalID != nil {
+			runCtx = context.WithValue(ctx, approvedKey{}, *instruction.ApprovalID)
+		}
+		result, err := s.accounts.withDB(tx).Transfer(runCtx, instruction.SourceAccountID, instruction.DestinationAccountID, instruction.Amount, description, instruction.CustomerIDs)
+		var domainErr *Error
+		switch {
+		case err == nil:
+			execution.Status = models.ExecutionSucceeded
+			execution.DebitTransactionID = &result.Debit.ID
+			execution.CreditTransactionID = &result.Credit.ID
+			instruction.ConsecutiveFailures = 0
+			advanceOccurrence(&instruction)
+		case errors.As(err, &domainErr):
+			execution.Status = models.ExecutionFailed
+			execution.FailureCode = domainErr.Code
+			execution.FailureReason = truncate(domainErr.Message, 255)
+			instruction.ConsecutiveFailures++
+			switch {
+			case instruction.ConsecutiveFailures >= instruction.PauseAfterFailures:
+				instruction.Status = models.InstructionPaused
+				instruction.PausedReason = fmt.Sprintf("paused after %d consecutive failures, last: %s", instruction.ConsecutiveFailures, domainErr.Message)
+				instruction.Attempts = execution.Attempt
+			case execution.Attempt <= instruction.MaxRetries:
+				instruction.Attempts = execution.Attempt
+				instruction.NextRunAt = now.Add(time.Duration(instruction.RetryIntervalHours) * time.Hour)
+			default:
+				//out of retries, this occurrence is missed
+				advanceOccurrence(&instruction)
+			}
+		default:
+			return err
+		}
+
+		if err := tx.Create(execution).Error; err != nil {
+			return err
+		}
+		instruction.LastExecutedAt = &now
+		instruction.Version++
+		return saveInstruction(tx, &instruction)
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("standing instruction execution failed", "instruction_id", id, "error", err)
+		return nil, err
+	}
+
+	if execution != nil {
+		log := logging.FromContext(ctx).With("instruction_id", id, "due_date", execution.DueDate, "attempt", execution.Attempt)
+		if execution.Status == models.ExecutionSucceeded {
+			log.Info("standing instruction executed", "amount", execution.Amount)
+		} else {
+			log.Warn("standing instruction attempt failed", "code", execution.FailureCode, "status", instruction.Status)
+		}
+	}
+	return execution, nil
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"gorm.io/gorm"
+)
+
+// ensureExists reports a field-level validation error when a referenced row is missing, so
+// clients learn which id was wrong instead of getting a foreign key violation.
+func ensureExists(db *gorm.DB, model interface{}, id uint, field, entity string) error {
+	var count int64
+	if err := db.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		err := FieldValidationError(FieldError{Field: field, Message: entity + " does not exist"})
+		err.Code = "invalid_reference"
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get and Delete for keys that hold no blob.
+var ErrNotFound = errors.New("storage: blob not found")
+
+// BlobStore keeps opaque files under slash-separated keys. Implementations must be safe for
+// concurrent use.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}